@@ -41,19 +41,55 @@ func ConnectDB(cfg *config.Config) (*DBInstance, error) {
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	if err := failInterruptedJobs(db); err != nil {
+		return nil, fmt.Errorf("failed to reset interrupted jobs: %w", err)
+	}
+
 	return &DBInstance{
 		DB: db,
 	}, nil
 }
 
+// failInterruptedJobs marks jobs a previous process left pending or running
+// as failed. Jobs run in goroutines of the process that started them, so
+// these died with it and would otherwise block their import for good.
+func failInterruptedJobs(db *gorm.DB) error {
+	result := db.Model(&models.AsyncJob{}).
+		Where("status IN ?", []models.Status{models.Pending, models.Running}).
+		Updates(models.AsyncJob{Status: models.Error, Error: "Interrupted by a server restart, run it again"})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Marked %d interrupted async jobs as failed", result.RowsAffected)
+	}
+	return nil
+}
+
 func runMigrations(db *gorm.DB) error {
 	models := []any{
 		&models.User{},
 		&models.Exercise{},
 		&models.Record{},
 		&models.Set{},
+		&models.PersonalRecord{},
 		&models.AsyncJob{},
 		&models.Workout{},
+		&models.WorkoutGroup{},
+		&models.WorkoutTemplate{},
+		&models.WorkoutTemplateExercise{},
+		&models.Program{},
+		&models.ProgramWeek{},
+		&models.ProgramDay{},
+		&models.ProgramPrescription{},
+		&models.ProgramTrainingMax{},
+		&models.ProgramProgressRule{},
+		&models.ProgramSession{},
+		&models.Goal{},
+		&models.GoalEntry{},
+		&models.IdempotencyKey{},
+		&models.Import{},
+		&models.ExerciseMapping{},
 	}
 
 	for _, model := range models {
@@ -62,5 +98,5 @@ func runMigrations(db *gorm.DB) error {
 		}
 	}
 
-	return nil
+	return runDataMigrations(db)
 }
@@ -0,0 +1,342 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/internal/strength"
+	"github.com/nagy135/fitness-tracker/models"
+	"gorm.io/gorm"
+)
+
+// SchemaMigration keeps track of one-off data migrations that already ran
+type SchemaMigration struct {
+	ID        string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+type dataMigration struct {
+	ID  string
+	Run func(tx *gorm.DB) error
+}
+
+// dataMigrations run once, in order, after AutoMigrate has updated the schema
+var dataMigrations = []dataMigration{
+	{ID: "0001_backfill_record_workout_id", Run: backfillRecordWorkoutID},
+	{ID: "0002_backfill_personal_records", Run: backfillPersonalRecords},
+	{ID: "0003_stats_day_indexes", Run: createStatsDayIndexes},
+	{ID: "0004_user_units_kg", Run: backfillUserUnits},
+	{ID: "0005_recompute_personal_records", Run: recomputePersonalRecords},
+	{ID: "0006_exercise_foreign_keys", Run: addExerciseForeignKeys},
+	{ID: "0007_heaviest_single_one_rep", Run: recomputePersonalRecords},
+	{ID: "0008_local_midnight_dates", Run: moveDatesToLocalMidnight},
+	{ID: "0009_client_ids_per_user", Run: dropGlobalClientIDIndexes},
+	{ID: "0010_sync_change_xid", Run: addSyncChangeTriggers},
+	{ID: "0011_goal_dates_local_midnight", Run: moveGoalDatesToLocalMidnight},
+	{ID: "0012_purge_deleted_personal_records", Run: purgeDeletedPersonalRecords},
+}
+
+func runDataMigrations(db *gorm.DB) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+
+	for _, migration := range dataMigrations {
+		var count int64
+		if err := db.Model(&SchemaMigration{}).Where("id = ?", migration.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		log.Printf("Running data migration %s", migration.ID)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Run(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{ID: migration.ID, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("data migration %s: %w", migration.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// backfillRecordWorkoutID links existing records to the workout logged on the
+// same day. When a day has several workouts, the record goes to the latest
+// workout created before it (or the first one of the day if none was).
+func backfillRecordWorkoutID(tx *gorm.DB) error {
+	if err := tx.Exec(`
+		UPDATE records r SET workout_id = (
+			SELECT w.id FROM workouts w
+			WHERE w.user_id = r.user_id
+				AND w.deleted_at IS NULL
+				AND COALESCE(w.date, w.created_at)::date = COALESCE(r.date, r.created_at)::date
+			ORDER BY
+				CASE WHEN w.created_at <= r.created_at THEN 0 ELSE 1 END,
+				CASE WHEN w.created_at <= r.created_at THEN w.created_at END DESC,
+				w.created_at ASC
+			LIMIT 1
+		)
+		WHERE r.workout_id IS NULL AND r.deleted_at IS NULL
+	`).Error; err != nil {
+		return err
+	}
+
+	// Number the linked records in the order they were logged
+	return tx.Exec(`
+		UPDATE records r SET position = ordered.position
+		FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY workout_id ORDER BY created_at, id) - 1 AS position
+			FROM records
+			WHERE workout_id IS NOT NULL AND deleted_at IS NULL
+		) ordered
+		WHERE r.id = ordered.id
+	`).Error
+}
+
+// createStatsDayIndexes indexes the day expression stats group records and
+// workouts by, the plain (user_id, date) indexes come from the model tags
+func createStatsDayIndexes(tx *gorm.DB) error {
+	if err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_records_user_day ON records (user_id, (COALESCE(date, created_at))) WHERE deleted_at IS NULL").Error; err != nil {
+		return err
+	}
+	return tx.Exec("CREATE INDEX IF NOT EXISTS idx_workouts_user_day ON workouts (user_id, (COALESCE(date, created_at))) WHERE deleted_at IS NULL").Error
+}
+
+// backfillPersonalRecords replays every user's records in date order to build
+// the PR history that existed before PRs were detected on write
+func backfillPersonalRecords(tx *gorm.DB) error {
+	var records []models.Record
+	result := tx.Preload("Exercise").Preload("Sets").
+		Where("placeholder = ?", false).
+		Order("user_id, exercise_id, COALESCE(date, created_at), created_at, id").
+		Find(&records)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	locations, err := userLocations(tx)
+	if err != nil {
+		return err
+	}
+
+	var prior []models.Record
+	for i, record := range records {
+		if i > 0 && (records[i-1].UserID != record.UserID || records[i-1].ExerciseID != record.ExerciseID) {
+			prior = nil
+		}
+
+		loc, ok := locations[record.UserID]
+		if !ok {
+			loc = time.UTC
+		}
+
+		detected := strength.DetectPersonalRecords(prior, record, record.Exercise, loc)
+		if len(detected) > 0 {
+			if err := tx.Create(&detected).Error; err != nil {
+				return err
+			}
+		}
+
+		prior = append(prior, record)
+	}
+
+	return nil
+}
+
+// userLocations maps users to their timezone, unknown names fall back to UTC
+func userLocations(tx *gorm.DB) (map[uint]*time.Location, error) {
+	var users []models.User
+	if err := tx.Select("id, timezone").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	locations := make(map[uint]*time.Location, len(users))
+	for _, user := range users {
+		loc, err := time.LoadLocation(user.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		locations[user.ID] = loc
+	}
+	return locations, nil
+}
+
+// backfillUserUnits marks every existing user as training in kilograms, which
+// is what weights were logged in before units existed. Stored weights are
+// already kilograms and stay untouched.
+func backfillUserUnits(tx *gorm.DB) error {
+	return tx.Exec("UPDATE users SET unit = 'kg' WHERE unit IS NULL OR unit = ''").Error
+}
+
+// recomputePersonalRecords rebuilds the PR history once weights are stored as
+// numeric. AutoMigrate casts the old real columns, which keeps set weights as
+// entered, but PR values were float sums and only keep six significant digits
+// through the cast, so they are computed again from the sets.
+func recomputePersonalRecords(tx *gorm.DB) error {
+	if err := tx.Exec("DELETE FROM personal_records").Error; err != nil {
+		return err
+	}
+	return backfillPersonalRecords(tx)
+}
+
+// moveDatesToLocalMidnight moves plain days logged before timezones existed,
+// stored as midnight UTC, to midnight in the user's timezone so they stay on
+// their calendar day once it has an offset. Users without a timezone are on
+// UTC already.
+func moveDatesToLocalMidnight(tx *gorm.DB) error {
+	for _, table := range []string{"workouts", "records", "personal_records"} {
+		err := tx.Exec(`
+			UPDATE ` + table + ` t
+			SET date = (t.date AT TIME ZONE 'UTC')::date::timestamp AT TIME ZONE u.timezone, updated_at = NOW()
+			FROM users u
+			WHERE u.id = t.user_id
+				AND u.timezone IS NOT NULL AND u.timezone <> ''
+				AND t.date IS NOT NULL
+				AND (t.date AT TIME ZONE 'UTC')::time = '00:00'
+		`).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropGlobalClientIDIndexes drops the unique indexes that made client IDs
+// unique across all users. AutoMigrate already created the ones scoped to
+// the user, or to the record for sets.
+func dropGlobalClientIDIndexes(tx *gorm.DB) error {
+	for _, index := range []string{"idx_workouts_client_id", "idx_records_client_id", "idx_sets_client_id"} {
+		if err := tx.Exec("DROP INDEX IF EXISTS " + index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncedTables are the tables sync pulls changes of
+var syncedTables = []string{"workouts", "records", "sets", "exercises"}
+
+// addSyncChangeTriggers stamps every insert and update of a synced table,
+// soft deletes included, with the ID of the writing transaction. Rows written
+// before stay at 0 and are only part of a full sync.
+func addSyncChangeTriggers(tx *gorm.DB) error {
+	err := tx.Exec(`
+		CREATE OR REPLACE FUNCTION set_change_xid() RETURNS trigger AS $$
+		BEGIN
+			NEW.change_xid := txid_current();
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql
+	`).Error
+	if err != nil {
+		return err
+	}
+
+	for _, table := range syncedTables {
+		if err := tx.Exec("DROP TRIGGER IF EXISTS " + table + "_change_xid ON " + table).Error; err != nil {
+			return err
+		}
+		err := tx.Exec("CREATE TRIGGER " + table + "_change_xid BEFORE INSERT OR UPDATE ON " + table +
+			" FOR EACH ROW EXECUTE FUNCTION set_change_xid()").Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exerciseReferences are the tables whose exercise_id points at exercises
+var exerciseReferences = []string{
+	"records",
+	"personal_records",
+	"workout_template_exercises",
+	"program_prescriptions",
+	"program_training_maxes",
+	"program_progress_rules",
+	"goals",
+}
+
+// addExerciseForeignKeys makes exercise_id a real foreign key wherever it is
+// not one yet. Rows already pointing at missing exercises are kept, the
+// constraint is then left NOT VALID so it only guards new writes.
+func addExerciseForeignKeys(tx *gorm.DB) error {
+	for _, table := range exerciseReferences {
+		var existing int64
+		err := tx.Raw(`
+			SELECT COUNT(*) FROM pg_constraint c
+			JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY(c.conkey)
+			WHERE c.contype = 'f' AND c.conrelid = ?::regclass
+				AND c.confrelid = 'exercises'::regclass AND a.attname = 'exercise_id'
+		`, table).Scan(&existing).Error
+		if err != nil {
+			return err
+		}
+		if existing > 0 {
+			continue
+		}
+
+		constraint := "fk_" + table + "_exercise"
+		if err := tx.Exec("ALTER TABLE " + table + " ADD CONSTRAINT " + constraint + " FOREIGN KEY (exercise_id) REFERENCES exercises(id) NOT VALID").Error; err != nil {
+			return err
+		}
+
+		var orphans int64
+		err = tx.Raw("SELECT COUNT(*) FROM " + table + " t WHERE t.exercise_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM exercises e WHERE e.id = t.exercise_id)").Scan(&orphans).Error
+		if err != nil {
+			return err
+		}
+		if orphans > 0 {
+			log.Printf("%s has %d rows referencing missing exercises, %s left NOT VALID", table, orphans, constraint)
+			continue
+		}
+
+		if err := tx.Exec("ALTER TABLE " + table + " VALIDATE CONSTRAINT " + constraint).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// moveGoalDatesToLocalMidnight stores goal entry dates and deadlines as
+// midnight in the user's timezone like other plain days. Plain days were
+// stored at midnight UTC, entries logged without a date at the moment they
+// were logged, which moves to the day that was in the user's timezone.
+func moveGoalDatesToLocalMidnight(tx *gorm.DB) error {
+	err := tx.Exec(`
+		UPDATE goal_entries e
+		SET date = (CASE
+				WHEN (e.date AT TIME ZONE 'UTC')::time = '00:00' THEN (e.date AT TIME ZONE 'UTC')::date
+				ELSE (e.date AT TIME ZONE COALESCE(NULLIF(u.timezone, ''), 'UTC'))::date
+			END)::timestamp AT TIME ZONE COALESCE(NULLIF(u.timezone, ''), 'UTC'),
+			updated_at = NOW()
+		FROM goals g
+		JOIN users u ON u.id = g.user_id
+		WHERE g.id = e.goal_id
+	`).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Exec(`
+		UPDATE goals g
+		SET deadline = (g.deadline AT TIME ZONE 'UTC')::date::timestamp AT TIME ZONE u.timezone, updated_at = NOW()
+		FROM users u
+		WHERE u.id = g.user_id
+			AND u.timezone IS NOT NULL AND u.timezone <> ''
+			AND g.deadline IS NOT NULL
+			AND (g.deadline AT TIME ZONE 'UTC')::time = '00:00'
+	`).Error
+}
+
+// purgeDeletedPersonalRecords drops PRs that were soft deleted. PRs are
+// derived from the records and are deleted for good now.
+func purgeDeletedPersonalRecords(tx *gorm.DB) error {
+	return tx.Exec("DELETE FROM personal_records WHERE deleted_at IS NOT NULL").Error
+}
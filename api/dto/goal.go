@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/models"
+)
+
+type GoalDto struct {
+	Type       string           `json:"type" validate:"required,oneof=estimated-1rm sessions bodyweight"`
+	ExerciseID *uint            `json:"exerciseId,omitempty" validate:"required_if=Type estimated-1rm,omitempty,min=1"`
+	Period     *string          `json:"period,omitempty" validate:"required_if=Type sessions,omitempty,oneof=week month"`
+	Target     decimal.Decimal  `json:"target" validate:"required,gt=0"`
+	StartValue *decimal.Decimal `json:"startValue,omitempty" validate:"required_if=Type bodyweight,omitempty,min=0"`
+	Deadline   *string          `json:"deadline,omitempty" validate:"omitempty,datetime=2006-01-02"`
+}
+
+type GoalEntryDto struct {
+	Value decimal.Decimal `json:"value" validate:"required,gt=0"`
+	Date  *string         `json:"date,omitempty" validate:"omitempty,datetime=2006-01-02"`
+}
+
+type GoalProgressDto struct {
+	Current decimal.Decimal `json:"current"`
+	Percent float64         `json:"percent"`
+	// Achieved is true once the current value reached the target
+	Achieved bool `json:"achieved"`
+	// ProjectedCompletion is when the trend reaches the target, empty when
+	// it doesn't head there
+	ProjectedCompletion *string `json:"projectedCompletion,omitempty"`
+	// OnTrack compares the projection to the deadline, or the end of the
+	// period for session goals
+	OnTrack *bool `json:"onTrack,omitempty"`
+}
+
+type GoalWithProgressDto struct {
+	models.Goal
+	Progress GoalProgressDto `json:"progress"`
+}
@@ -0,0 +1,23 @@
+package dto
+
+// ImportDto is the form an export is uploaded with, next to its file.
+// Imports are dry runs unless asked otherwise.
+type ImportDto struct {
+	Source string `form:"source" validate:"required,oneof=strong hevy fitnotes"`
+	DryRun *bool  `form:"dryRun"`
+}
+
+type ImportMappingDto struct {
+	Name       string `json:"name" validate:"required,min=1,max=255"`
+	ExerciseID *uint  `json:"exerciseId,omitempty" validate:"omitempty,min=1"`
+}
+
+// ImportMappingsDto confirms where exercise names of an import go, an empty
+// exerciseId leaves the exercise out
+type ImportMappingsDto struct {
+	Mappings []ImportMappingDto `json:"mappings" validate:"required,min=1,dive"`
+}
+
+type ImportRunDto struct {
+	DryRun bool `json:"dryRun"`
+}
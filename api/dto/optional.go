@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Optional is a field of an update that tells a field left out of the JSON
+// apart from one explicitly set to null. Set is false when the field was
+// absent, Value is nil when it was cleared.
+type Optional[T any] struct {
+	Set   bool
+	Value *T
+}
+
+// UnmarshalJSON only runs for fields present in the JSON
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		o.Value = nil
+		return nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	o.Value = &value
+	return nil
+}
+
+// MarshalJSON writes the value, or null when unset or cleared
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Value)
+}
@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/models"
+)
+
+// PRSetDto is a personal record achieved by a single set
+type PRSetDto struct {
+	Value    decimal.Decimal `json:"value"`
+	Weight   decimal.Decimal `json:"weight"`
+	Reps     int             `json:"reps"`
+	RecordID uint            `json:"recordId"`
+	SetID    uint            `json:"setId"`
+	Date     string          `json:"date"`
+}
+
+// SessionPRDto is a personal record achieved over a whole day of training
+type SessionPRDto struct {
+	Value     decimal.Decimal `json:"value"`
+	Date      string          `json:"date"`
+	RecordIDs []uint          `json:"recordIds"`
+}
+
+type ExercisePRsDto struct {
+	Formula            string        `json:"formula"`
+	EstimatedOneRepMax *PRSetDto     `json:"estimatedOneRepMax"`
+	HeaviestSingle     *PRSetDto     `json:"heaviestSingle"`
+	BestSetVolume      *PRSetDto     `json:"bestSetVolume"`
+	BestSessionVolume  *SessionPRDto `json:"bestSessionVolume"`
+	RepPRs             []PRSetDto    `json:"repPRs"`
+}
+
+// RecordWithPRsDto is a saved record along with the PRs it just set
+type RecordWithPRsDto struct {
+	models.Record
+	PersonalRecords []models.PersonalRecord `json:"personalRecords"`
+}
@@ -0,0 +1,85 @@
+package dto
+
+import "github.com/nagy135/fitness-tracker/internal/decimal"
+
+type ProgramPrescriptionDto struct {
+	ExerciseID           uint     `json:"exerciseId" validate:"required,min=1"`
+	Sets                 int      `json:"sets" validate:"required,min=1,max=20"`
+	Reps                 int      `json:"reps" validate:"required,min=1"`
+	PercentOfTrainingMax *float32 `json:"percentOfTrainingMax,omitempty" validate:"omitempty,min=1,max=150"`
+	Amrap                bool     `json:"amrap"`
+}
+
+type ProgramDayDto struct {
+	Label             string                   `json:"label" validate:"required,min=1,max=100"`
+	WorkoutTemplateID uint                     `json:"workoutTemplateId" validate:"required,min=1"`
+	Prescriptions     []ProgramPrescriptionDto `json:"prescriptions,omitempty" validate:"omitempty,dive"`
+}
+
+type ProgramWeekDto struct {
+	Days []ProgramDayDto `json:"days" validate:"required,min=1,dive"`
+}
+
+type ProgramTrainingMaxDto struct {
+	ExerciseID uint            `json:"exerciseId" validate:"required,min=1"`
+	Weight     decimal.Decimal `json:"weight" validate:"required,gt=0"`
+}
+
+type ProgramProgressRuleDto struct {
+	ExerciseID       uint            `json:"exerciseId" validate:"required,min=1"`
+	Increment        decimal.Decimal `json:"increment" validate:"required,gt=0"`
+	FailureThreshold int             `json:"failureThreshold" validate:"required,min=1"`
+	DeloadPercent    float32         `json:"deloadPercent" validate:"required,gt=0,max=50"`
+}
+
+type ProgramDto struct {
+	Name          string                   `json:"name" validate:"required,min=1,max=100"`
+	Description   *string                  `json:"description,omitempty" validate:"omitempty,max=1000"`
+	Weeks         []ProgramWeekDto         `json:"weeks" validate:"required,min=1,dive"`
+	TrainingMaxes []ProgramTrainingMaxDto  `json:"trainingMaxes,omitempty" validate:"omitempty,dive"`
+	Rules         []ProgramProgressRuleDto `json:"rules,omitempty" validate:"omitempty,dive"`
+}
+
+type CompleteProgramDayDto struct {
+	WorkoutID uint `json:"workoutId" validate:"required,min=1"`
+}
+
+type PrescribedExerciseDto struct {
+	ExerciseID           uint             `json:"exerciseId"`
+	ExerciseName         string           `json:"exerciseName"`
+	Sets                 int              `json:"sets"`
+	Reps                 int              `json:"reps"`
+	Weight               *decimal.Decimal `json:"weight,omitempty"`
+	PercentOfTrainingMax *float32         `json:"percentOfTrainingMax,omitempty"`
+	TrainingMax          *decimal.Decimal `json:"trainingMax,omitempty"`
+	Amrap                bool             `json:"amrap"`
+}
+
+type ProgramTodayDto struct {
+	ProgramID         uint                    `json:"programId"`
+	Cycle             int                     `json:"cycle"`
+	Week              int                     `json:"week"`
+	ProgramDayID      uint                    `json:"programDayId"`
+	Label             string                  `json:"label"`
+	WorkoutTemplateID uint                    `json:"workoutTemplateId"`
+	Exercises         []PrescribedExerciseDto `json:"exercises"`
+}
+
+type ExerciseProgressionDto struct {
+	ExerciseID     uint             `json:"exerciseId"`
+	Succeeded      bool             `json:"succeeded"`
+	Failures       int              `json:"failures"`
+	PreviousMax    *decimal.Decimal `json:"previousTrainingMax,omitempty"`
+	TrainingMax    *decimal.Decimal `json:"trainingMax,omitempty"`
+	Deloaded       bool             `json:"deloaded"`
+	CompletedSets  int              `json:"completedSets"`
+	PrescribedSets int              `json:"prescribedSets"`
+}
+
+type ProgramSessionResultDto struct {
+	ProgramID uint                     `json:"programId"`
+	WorkoutID uint                     `json:"workoutId"`
+	Succeeded bool                     `json:"succeeded"`
+	Exercises []ExerciseProgressionDto `json:"exercises"`
+	Next      *ProgramTodayDto         `json:"next,omitempty"`
+}
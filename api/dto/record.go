@@ -1,18 +1,59 @@
 package dto
 
+import "github.com/nagy135/fitness-tracker/internal/decimal"
+
 type SetDto struct {
-	Reps   int     `json:"reps" validate:"required,min=1"`
-	Weight float32 `json:"weight" validate:"required,min=0"`
+	Reps   int             `json:"reps" validate:"required,min=1"`
+	Weight decimal.Decimal `json:"weight" validate:"required,min=0"`
 }
 
 type RecordDto struct {
 	ExerciseID uint     `json:"exerciseId" validate:"required,min=1"`
 	Sets       []SetDto `json:"sets" validate:"required,min=1,dive"`
 	Date       *string  `json:"date,omitempty"`
+	WorkoutID  *uint    `json:"workoutId,omitempty" validate:"omitempty,min=1"`
+}
+
+// RecordBatchDto creates many records at once, each item is validated on its
+// own so errors can point at it
+type RecordBatchDto struct {
+	Records []RecordDto `json:"records" validate:"required,min=1,max=100"`
 }
 
 type UpdateRecordDto struct {
 	ExerciseID uint     `json:"exerciseId" validate:"required,min=1"`
 	Sets       []SetDto `json:"sets" validate:"required,min=1,dive"`
-	Date       *string  `json:"date,omitempty"`
+	WorkoutID  *uint    `json:"workoutId,omitempty" validate:"omitempty,min=1"`
+
+	// Absent keeps the current date, null clears it
+	Date Optional[string] `json:"date"`
+}
+
+// PatchRecordDto changes only the fields present, sets are edited through
+// their own endpoints
+type PatchRecordDto struct {
+	ExerciseID *uint `json:"exerciseId,omitempty" validate:"omitempty,min=1"`
+
+	// null detaches the record from its workout
+	WorkoutID Optional[uint] `json:"workoutId"`
+
+	// null clears the date
+	Date Optional[string] `json:"date"`
+}
+
+type PatchSetDto struct {
+	Reps   *int             `json:"reps,omitempty" validate:"omitempty,min=1"`
+	Weight *decimal.Decimal `json:"weight,omitempty" validate:"omitempty,min=0"`
+}
+
+type RecordsQueryDto struct {
+	Cursor           string `query:"cursor"`
+	Limit            int    `query:"limit" validate:"omitempty,min=1,max=200"`
+	From             string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To               string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+	ExerciseIDs      []uint `query:"exerciseId" validate:"omitempty,dive,min=1"`
+	Muscle           string `query:"muscle"`
+	IncludeSecondary bool   `query:"includeSecondary"`
+	Category         string `query:"category"`
+	Sort             string `query:"sort" validate:"omitempty,oneof=date_desc date_asc"`
 }
@@ -0,0 +1,108 @@
+package dto
+
+import "github.com/nagy135/fitness-tracker/internal/decimal"
+
+type StatsRangeQueryDto struct {
+	From string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To   string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+}
+
+type MuscleStatsQueryDto struct {
+	From            string   `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To              string   `query:"to" validate:"omitempty,datetime=2006-01-02"`
+	Period          string   `query:"period" validate:"omitempty,oneof=week month"`
+	SecondaryWeight *float64 `query:"secondaryWeight" validate:"omitempty,min=0,max=1"`
+	HardSetPercent  *float64 `query:"hardSetPercent" validate:"omitempty,min=0,max=1"`
+}
+
+type MuscleBucketStatsDto struct {
+	Muscle    string          `json:"muscle"`
+	Volume    decimal.Decimal `json:"volume"`
+	HardSets  float64         `json:"hardSets"`
+	Frequency int             `json:"frequency"` // distinct training days
+}
+
+type MuscleStatsBucketDto struct {
+	Start   string                 `json:"start"`
+	Muscles []MuscleBucketStatsDto `json:"muscles"`
+}
+
+type ExerciseSeriesQueryDto struct {
+	From      string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To        string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+	Bucket    string `query:"bucket" validate:"omitempty,oneof=session week"`
+	Formula   string `query:"formula" validate:"omitempty,oneof=epley brzycki"`
+	Smoothing int    `query:"smoothing" validate:"omitempty,min=1,max=26"` // moving average window in points
+}
+
+type SmoothedSeriesDto struct {
+	EstimatedOneRepMax decimal.Decimal `json:"estimatedOneRepMax"`
+	Volume             decimal.Decimal `json:"volume"`
+	AverageIntensity   decimal.Decimal `json:"averageIntensity"`
+}
+
+type ExerciseSeriesPointDto struct {
+	Date               string             `json:"date"`
+	Sessions           int                `json:"sessions"`
+	TopSetWeight       decimal.Decimal    `json:"topSetWeight"`
+	TopSetReps         int                `json:"topSetReps"`
+	EstimatedOneRepMax decimal.Decimal    `json:"estimatedOneRepMax"`
+	Volume             decimal.Decimal    `json:"volume"`
+	TotalReps          int                `json:"totalReps"`
+	AverageIntensity   decimal.Decimal    `json:"averageIntensity"` // volume per rep
+	Smoothed           *SmoothedSeriesDto `json:"smoothed,omitempty"`
+}
+
+type LoadQueryDto struct {
+	From              string   `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To                string   `query:"to" validate:"omitempty,datetime=2006-01-02"`
+	ACWRThreshold     *float64 `query:"acwrThreshold" validate:"omitempty,min=0"`
+	MonotonyThreshold *float64 `query:"monotonyThreshold" validate:"omitempty,min=0"`
+	StrainThreshold   *float64 `query:"strainThreshold" validate:"omitempty,min=0"` // off unless given
+}
+
+type LoadWarningDto struct {
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+}
+
+type LoadDayDto struct {
+	Date     string           `json:"date"`
+	Volume   decimal.Decimal  `json:"volume"`
+	Acute    decimal.Decimal  `json:"acute"`   // average daily volume over 7 days
+	Chronic  decimal.Decimal  `json:"chronic"` // average daily volume over 28 days
+	ACWR     float64          `json:"acwr"`
+	Monotony float64          `json:"monotony"`
+	Strain   decimal.Decimal  `json:"strain"`
+	Warnings []LoadWarningDto `json:"warnings,omitempty"`
+}
+
+type ConsistencyQueryDto struct {
+	Year int `query:"year" validate:"omitempty,min=1970,max=9999"`
+}
+
+type HeatMapDayDto struct {
+	Date     string          `json:"date"`
+	Sessions int             `json:"sessions"`
+	Volume   decimal.Decimal `json:"volume"`
+}
+
+type WeekAdherenceDto struct {
+	Start    string `json:"start"`
+	Sessions int    `json:"sessions"`
+	Met      bool   `json:"met"`
+}
+
+type StreaksDto struct {
+	Current int `json:"current"` // weeks in a row meeting the target
+	Longest int `json:"longest"`
+}
+
+type AdherenceDto struct {
+	WeeklyTarget int                `json:"weeklyTarget"`
+	WeeksMet     int                `json:"weeksMet"`
+	WeeksElapsed int                `json:"weeksElapsed"`
+	Percent      float64            `json:"percent"`
+	Weeks        []WeekAdherenceDto `json:"weeks"`
+}
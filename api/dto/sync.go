@@ -0,0 +1,115 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/models"
+)
+
+type SyncQueryDto struct {
+	// Cursor returned by the previous sync, empty for a full sync
+	Since string `query:"since"`
+}
+
+// SyncChangesDto lists what changed of one entity since the cursor
+type SyncChangesDto[T any] struct {
+	Updated []T    `json:"updated"`
+	Deleted []uint `json:"deleted"`
+}
+
+// SyncRecordDto is a record without its exercise and sets, both are synced
+// on their own
+type SyncRecordDto struct {
+	ID             uint       `json:"id"`
+	ClientID       *string    `json:"clientId,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	Version        uint       `json:"version"`
+	ExerciseID     uint       `json:"exerciseId"`
+	Date           *time.Time `json:"date,omitempty"`
+	WorkoutID      *uint      `json:"workoutId,omitempty"`
+	Position       int        `json:"position"`
+	WorkoutGroupID *uint      `json:"workoutGroupId,omitempty"`
+	Placeholder    bool       `json:"placeholder"`
+}
+
+type SyncPullDto struct {
+	Workouts  SyncChangesDto[models.Workout]  `json:"workouts"`
+	Records   SyncChangesDto[SyncRecordDto]   `json:"records"`
+	Sets      SyncChangesDto[models.Set]      `json:"sets"`
+	Exercises SyncChangesDto[models.Exercise] `json:"exercises"`
+
+	// Pass as since on the next sync
+	Cursor string `json:"cursor"`
+}
+
+type SyncPushDto struct {
+	Mutations []SyncMutationDto `json:"mutations" validate:"required,min=1,max=500,dive"`
+}
+
+// SyncMutationDto is a change made on the client while offline. Entities are
+// addressed by their server ID or by the client ID they were created with.
+type SyncMutationDto struct {
+	Entity   string `json:"entity" validate:"required,oneof=workout record set"`
+	Op       string `json:"op" validate:"required,oneof=create update delete"`
+	ID       *uint  `json:"id,omitempty" validate:"omitempty,min=1"`
+	ClientID string `json:"clientId,omitempty" validate:"omitempty,max=64"`
+
+	// Version of the workout or record the change was made on, an outdated
+	// one is reported as a conflict
+	Version *uint `json:"version,omitempty"`
+
+	// SyncWorkoutDataDto, SyncRecordDataDto or SyncSetDataDto by entity
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type SyncWorkoutDataDto struct {
+	Label *string          `json:"label,omitempty" validate:"omitempty,min=1,max=100"`
+	Date  Optional[string] `json:"date"`
+}
+
+type SyncRecordDataDto struct {
+	ExerciseID *uint `json:"exerciseId,omitempty" validate:"omitempty,min=1"`
+
+	// The workout by either ID, null detaches the record
+	WorkoutID       Optional[uint] `json:"workoutId"`
+	WorkoutClientID *string        `json:"workoutClientId,omitempty"`
+
+	Date Optional[string] `json:"date"`
+}
+
+type SyncSetDataDto struct {
+	// The record of a new set by either ID
+	RecordID       *uint   `json:"recordId,omitempty" validate:"omitempty,min=1"`
+	RecordClientID *string `json:"recordClientId,omitempty"`
+
+	Reps        *int             `json:"reps,omitempty" validate:"omitempty,min=1"`
+	Weight      *decimal.Decimal `json:"weight,omitempty" validate:"omitempty,min=0"`
+	PerformedAt *string          `json:"performedAt,omitempty"`
+}
+
+type SyncResultStatus string
+
+const (
+	SyncApplied  SyncResultStatus = "applied"
+	SyncConflict SyncResultStatus = "conflict"
+	SyncRejected SyncResultStatus = "rejected"
+)
+
+// SyncResultDto reports the outcome of one mutation, in the order they were sent
+type SyncResultDto struct {
+	Index    int              `json:"index"`
+	Entity   string           `json:"entity"`
+	Op       string           `json:"op"`
+	ClientID string           `json:"clientId,omitempty"`
+	Status   SyncResultStatus `json:"status"`
+
+	// Server ID of the entity and, for workouts and records, its version
+	// after the change or the current one on conflict
+	ID      uint `json:"id,omitempty"`
+	Version uint `json:"version,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
@@ -3,4 +3,10 @@ package dto
 type UserDto struct {
 	Name string `json:"name" validate:"required,min=3,max=50"`
 	Pass string `json:"pass" validate:"required,min=8,max=100"`
-} 
\ No newline at end of file
+} 
+
+type UserSettingsDto struct {
+	WeeklySessionTarget *int    `json:"weeklySessionTarget" validate:"omitempty,min=1,max=14"`
+	Unit                *string `json:"unit" validate:"omitempty,oneof=kg lb"`
+	Timezone            *string `json:"timezone" validate:"omitempty,timezone"`
+}
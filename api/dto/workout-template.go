@@ -0,0 +1,20 @@
+package dto
+
+import "github.com/nagy135/fitness-tracker/internal/decimal"
+
+type WorkoutTemplateExerciseDto struct {
+	ExerciseID   uint             `json:"exerciseId" validate:"required,min=1"`
+	TargetSets   int              `json:"targetSets" validate:"required,min=1,max=20"`
+	TargetReps   int              `json:"targetReps" validate:"required,min=1"`
+	TargetWeight *decimal.Decimal `json:"targetWeight,omitempty" validate:"omitempty,min=0"`
+}
+
+type WorkoutTemplateDto struct {
+	Name      string                       `json:"name" validate:"required,min=1,max=100"`
+	Exercises []WorkoutTemplateExerciseDto `json:"exercises" validate:"required,min=1,dive"`
+}
+
+type WorkoutFromTemplateDto struct {
+	Label *string `json:"label,omitempty" validate:"omitempty,min=1,max=100"`
+	Date  *string `json:"date,omitempty"`
+}
@@ -1,6 +1,61 @@
 package dto
 
+import (
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/models"
+)
+
 type WorkoutDto struct {
 	Label string  `json:"label" validate:"required,min=1,max=100"`
 	Date  *string `json:"date,omitempty"`
 }
+
+// PatchWorkoutDto changes only the fields present, null clears the date
+type PatchWorkoutDto struct {
+	Label *string          `json:"label,omitempty" validate:"omitempty,min=1,max=100"`
+	Date  Optional[string] `json:"date"`
+}
+
+type AddWorkoutRecordDto struct {
+	RecordID uint `json:"recordId" validate:"required,min=1"`
+}
+
+type ReorderWorkoutRecordsDto struct {
+	RecordIDs []uint `json:"recordIds" validate:"required,min=1,dive,min=1"`
+}
+
+type WorkoutGroupDto struct {
+	Type      models.WorkoutGroupType `json:"type" validate:"required,oneof=superset giant-set circuit"`
+	Label     *string                 `json:"label,omitempty" validate:"omitempty,min=1,max=20"`
+	RecordIDs []uint                  `json:"recordIds" validate:"required,min=2,dive,min=1"`
+}
+
+type SessionSetDto struct {
+	ExerciseID uint            `json:"exerciseId" validate:"required,min=1"`
+	Reps       int             `json:"reps" validate:"required,min=1"`
+	Weight     decimal.Decimal `json:"weight" validate:"required,min=0"`
+}
+
+type RestStatsDto struct {
+	Count          int     `json:"count"`
+	AverageSeconds float64 `json:"averageSeconds"`
+	MinSeconds     float64 `json:"minSeconds"`
+	MaxSeconds     float64 `json:"maxSeconds"`
+}
+
+type WorkoutSessionStatsDto struct {
+	Active          bool            `json:"active"`
+	DurationMinutes float64         `json:"durationMinutes"`
+	Volume          decimal.Decimal `json:"volume"`
+	Density         float64         `json:"density"` // volume per minute
+	SetCount        int             `json:"setCount"`
+	Rest            *RestStatsDto   `json:"rest,omitempty"`
+}
+
+type WorkoutDetailDto struct {
+	models.Workout
+	Session *WorkoutSessionStatsDto `json:"session,omitempty"`
+
+	// Outcome of the program day this workout completed, if any
+	Program *ProgramSessionResultDto `json:"program,omitempty"`
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/analytics"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+)
+
+// GetConsistencyStats returns weekly streaks, a heat-map of the year and
+// adherence to the user's weekly session target
+func (h *StatsHandler) GetConsistencyStats(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var consistencyQuery dto.ConsistencyQueryDto
+	if err := c.QueryParser(&consistencyQuery); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse query",
+		})
+	}
+
+	if errors := utils.ValidateStruct(consistencyQuery); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Weeks are counted in calendar days of the user's timezone
+	now := calendarDay(time.Now().In(loc))
+	year := consistencyQuery.Year
+	if year == 0 {
+		year = now.Year()
+	}
+
+	var user models.User
+	if err := h.db.DB.First(&user, userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	target := max(user.WeeklySessionTarget, 1)
+
+	// Streaks span the whole history, the heat-map only the year
+	sessions, err := queryDailySessionCounts(h.db.DB, userID, statsRange{Location: loc})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	yearStart := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	yearEnd := yearStart.AddDate(1, 0, 0)
+	localStart := localMidnight(yearStart, loc)
+	localEnd := localMidnight(yearEnd, loc)
+	volumes, err := queryDailyVolumes(h.db.DB, userID, statsRange{From: &localStart, To: &localEnd, Location: loc})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	volumesToUnit(volumes, unit)
+
+	heatMap := []dto.HeatMapDayDto{}
+	for day, count := range sessions {
+		if day < yearStart.Format("2006-01-02") || day >= yearEnd.Format("2006-01-02") {
+			continue
+		}
+		heatMap = append(heatMap, dto.HeatMapDayDto{
+			Date:     day,
+			Sessions: count,
+			Volume:   volumes[day],
+		})
+	}
+	sort.Slice(heatMap, func(i, j int) bool {
+		return heatMap[i].Date < heatMap[j].Date
+	})
+
+	weekly := analytics.WeeklySessions(sessions)
+
+	return c.JSON(fiber.Map{
+		"year":      year,
+		"streaks":   analytics.Streaks(weekly, target, now),
+		"heatMap":   heatMap,
+		"adherence": analytics.Adherence(weekly, target, year, now),
+	})
+}
+
+// queryDailySessionCounts counts sessions per day, every workout with records
+// is one and records outside of workouts make up one more
+func queryDailySessionCounts(db *gorm.DB, userID uint, statsRange statsRange) (map[string]int, error) {
+	rangeSQL, rangeArgs := statsRange.where("COALESCE(r.date, r.created_at)")
+
+	var rows []struct {
+		Day      string
+		Sessions int
+	}
+	err := db.Raw(`
+		SELECT `+recordDaySQL+` AS day,
+			COUNT(DISTINCT COALESCE(r.workout_id, 0)) AS sessions
+		FROM records r
+		WHERE r.user_id = ? AND r.deleted_at IS NULL AND r.placeholder = false`+rangeSQL+`
+		GROUP BY day
+	`, append([]any{statsRange.timezone(), userID}, rangeArgs...)...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]int, len(rows))
+	for _, row := range rows {
+		sessions[row.Day] = row.Sessions
+	}
+	return sessions, nil
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+)
+
+var errExercisesNotFound = errors.New("One or more exercises do not exist")
+
+// checkExercisesExist fails with errExercisesNotFound unless every given
+// exercise ID exists, deleted exercises don't count
+func checkExercisesExist(db *gorm.DB, exerciseIDs []uint) error {
+	ids := uniqueIDs(exerciseIDs)
+
+	var count int64
+	if err := db.Model(&models.Exercise{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if int(count) != len(ids) {
+		return errExercisesNotFound
+	}
+
+	return nil
+}
+
+// checkExerciseReference validates the exercise a record refers to, it
+// responds with 422 when the exercise doesn't exist and returns false
+func checkExerciseReference(c *fiber.Ctx, db *gorm.DB, field string, exerciseID uint) (bool, error) {
+	err := checkExercisesExist(db, []uint{exerciseID})
+	if err == nil {
+		return true, nil
+	}
+	return false, respondExerciseCheckError(c, err, field, exerciseID)
+}
+
+// respondExerciseCheckError answers a failed checkExercisesExist, with 422
+// when exercises don't exist and 500 when they couldn't be looked up. Value
+// is what the request gave for field.
+func respondExerciseCheckError(c *fiber.Ctx, err error, field string, value any) error {
+	if errors.Is(err, errExercisesNotFound) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": "Exercise not found",
+			"details": []utils.ValidationError{{
+				Field:   field,
+				Tag:     "exists",
+				Value:   value,
+				Message: "Exercise does not exist",
+			}},
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
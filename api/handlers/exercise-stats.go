@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/analytics"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/internal/strength"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+)
+
+// GetExerciseSeries returns per-session (or per-week) metrics of one exercise.
+// Volume and average intensity honor TotalWeightMultiplier, top set and
+// estimated max are reported as logged like the PR endpoint does.
+func (h *StatsHandler) GetExerciseSeries(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	exerciseID, err := c.ParamsInt("id")
+	if err != nil || exerciseID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid exercise ID",
+		})
+	}
+
+	var seriesQuery dto.ExerciseSeriesQueryDto
+	if err := c.QueryParser(&seriesQuery); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse query",
+		})
+	}
+
+	if errors := utils.ValidateStruct(seriesQuery); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	formula, _ := strength.ParseFormula(seriesQuery.Formula)
+
+	var exercise models.Exercise
+	if err := h.db.DB.First(&exercise, exerciseID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Exercise not found",
+		})
+	}
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	statsRange := newStatsRange(seriesQuery.From, seriesQuery.To, loc)
+	query := h.db.DB.Preload("Sets").
+		Where("user_id = ? AND exercise_id = ? AND placeholder = ?", userID, exerciseID, false)
+	if statsRange.From != nil {
+		query = query.Where("COALESCE(date, created_at) >= ?", *statsRange.From)
+	}
+	if statsRange.To != nil {
+		query = query.Where("COALESCE(date, created_at) < ?", *statsRange.To)
+	}
+
+	var records []models.Record
+	if err := query.Find(&records).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	recordsToUnit(records, unit)
+
+	bucketKey := func(record models.Record) string {
+		return strength.RecordDay(record, loc)
+	}
+	if seriesQuery.Bucket == "week" {
+		bucketKey = func(record models.Record) string {
+			return weekStart(strength.RecordDay(record, loc))
+		}
+	}
+
+	points := exerciseSeries(records, exercise, formula, loc, bucketKey)
+	if seriesQuery.Smoothing > 1 {
+		smoothSeries(points, seriesQuery.Smoothing)
+	}
+
+	bucket := seriesQuery.Bucket
+	if bucket == "" {
+		bucket = "session"
+	}
+
+	return c.JSON(fiber.Map{
+		"exerciseId": exercise.ID,
+		"bucket":     bucket,
+		"formula":    formula,
+		"series":     points,
+		"count":      len(points),
+	})
+}
+
+// exerciseSeries aggregates sets into one point per bucket, sorted by date.
+// Days in loc count as sessions, so weekly points report how many went into them.
+func exerciseSeries(records []models.Record, exercise models.Exercise, formula strength.Formula, loc *time.Location, bucketKey func(models.Record) string) []dto.ExerciseSeriesPointDto {
+	points := make(map[string]*dto.ExerciseSeriesPointDto)
+	sessions := make(map[string]map[string]bool)
+
+	for _, record := range records {
+		key := bucketKey(record)
+		point, ok := points[key]
+		if !ok {
+			point = &dto.ExerciseSeriesPointDto{Date: key}
+			points[key] = point
+			sessions[key] = make(map[string]bool)
+		}
+		sessions[key][strength.RecordDay(record, loc)] = true
+
+		for _, set := range record.Sets {
+			if set.Reps <= 0 {
+				continue
+			}
+
+			if set.Weight > point.TopSetWeight || (set.Weight == point.TopSetWeight && set.Reps > point.TopSetReps) {
+				point.TopSetWeight = set.Weight
+				point.TopSetReps = set.Reps
+			}
+
+			point.EstimatedOneRepMax = max(point.EstimatedOneRepMax, strength.EstimateOneRepMax(set.Weight, set.Reps, formula))
+			point.Volume += set.Weight.MulInt(set.Reps).MulFloat(float64(exercise.TotalWeightMultiplier))
+			point.TotalReps += set.Reps
+		}
+	}
+
+	series := make([]dto.ExerciseSeriesPointDto, 0, len(points))
+	for key, point := range points {
+		point.Sessions = len(sessions[key])
+		if point.TotalReps > 0 {
+			point.AverageIntensity = point.Volume.DivInt(point.TotalReps)
+		}
+		series = append(series, *point)
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Date < series[j].Date
+	})
+
+	return series
+}
+
+// smoothSeries adds a trailing moving average over the given number of points
+func smoothSeries(series []dto.ExerciseSeriesPointDto, window int) {
+	for i := range series {
+		start := max(0, i-window+1)
+		var smoothed dto.SmoothedSeriesDto
+		for _, point := range series[start : i+1] {
+			smoothed.EstimatedOneRepMax += point.EstimatedOneRepMax
+			smoothed.Volume += point.Volume
+			smoothed.AverageIntensity += point.AverageIntensity
+		}
+
+		count := i + 1 - start
+		smoothed.EstimatedOneRepMax = smoothed.EstimatedOneRepMax.DivInt(count)
+		smoothed.Volume = smoothed.Volume.DivInt(count)
+		smoothed.AverageIntensity = smoothed.AverageIntensity.DivInt(count)
+		series[i].Smoothed = &smoothed
+	}
+}
+
+// weekStart returns the Monday of the week a YYYY-MM-DD day falls in
+func weekStart(day string) string {
+	date, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return day
+	}
+	return analytics.WeekStart(date).Format("2006-01-02")
+}
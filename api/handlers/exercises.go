@@ -9,6 +9,7 @@ import (
 	"github.com/nagy135/fitness-tracker/internal/config"
 	"github.com/nagy135/fitness-tracker/models"
 	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
 )
 
 type ExerciseHandler struct {
@@ -22,10 +23,15 @@ func NewExerciseHandler(db *database.DBInstance, cfg *config.Config) *ExerciseHa
 
 // transformImageURLs converts relative image paths to full URLs
 func (h *ExerciseHandler) transformImageURLs(exercise *models.Exercise) {
+	imagesToURLs(exercise, h.cfg.Server.BaseURL)
+}
+
+// imagesToURLs prefixes the relative image paths of an exercise with baseURL
+func imagesToURLs(exercise *models.Exercise, baseURL string) {
 	if len(exercise.Images) > 0 {
 		fullImageURLs := make([]string, len(exercise.Images))
 		for i, imagePath := range exercise.Images {
-			fullImageURLs[i] = h.cfg.Server.BaseURL + imagePath
+			fullImageURLs[i] = baseURL + imagePath
 		}
 		exercise.Images = fullImageURLs
 	}
@@ -72,6 +78,7 @@ func (h *ExerciseHandler) GetExercise(c *fiber.Ctx) error {
 	// Transform relative image URLs to full URLs
 	h.transformImageURLs(&exercise)
 
+	setETag(c, exercise.Version)
 	return c.JSON(exercise)
 }
 
@@ -160,6 +167,7 @@ func (h *ExerciseHandler) CreateExercise(c *fiber.Ctx) error {
 	h.db.DB.First(&completeExercise, exercise.ID)
 	h.transformImageURLs(&completeExercise)
 
+	setETag(c, completeExercise.Version)
 	return c.Status(fiber.StatusCreated).JSON(completeExercise)
 }
 
@@ -194,8 +202,13 @@ func (h *ExerciseHandler) UpdateExercise(c *fiber.Ctx) error {
 		})
 	}
 
+	if ok, err := checkIfMatch(c, exercise.Version); !ok {
+		return err
+	}
+
 	// Update fields if provided
 	updates := make(map[string]interface{})
+	updates["version"] = gorm.Expr("version + 1")
 
 	if updateExerciseDto.Name != nil {
 		updates["name"] = *updateExerciseDto.Name
@@ -266,18 +279,24 @@ func (h *ExerciseHandler) UpdateExercise(c *fiber.Ctx) error {
 		updates["images"] = string(imagesJSON)
 	}
 
-	// Update the exercise
-	result = h.db.DB.Model(&exercise).Updates(updates)
+	// Update the exercise unless another request changed it since it was loaded
+	result = h.db.DB.Model(&exercise).Where("version = ?", exercise.Version).Updates(updates)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": result.Error.Error(),
 		})
 	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+			"error": errVersionConflict.Error(),
+		})
+	}
 
 	// Load the updated exercise with arrays populated
 	var updatedExercise models.Exercise
 	h.db.DB.First(&updatedExercise, id)
 	h.transformImageURLs(&updatedExercise)
 
+	setETag(c, updatedExercise.Version)
 	return c.JSON(updatedExercise)
 }
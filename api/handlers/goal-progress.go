@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/analytics"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/internal/strength"
+	"github.com/nagy135/fitness-tracker/models"
+	"gorm.io/gorm"
+)
+
+// goalTrendDays is how far back training data feeds the projection of
+// estimated 1RM goals
+const goalTrendDays = 90
+
+// evaluateGoal reports the current value of a goal, how much of it is done and
+// when the trend is projected to reach the target. Days are those of now's
+// location, the user's timezone.
+func evaluateGoal(db *gorm.DB, goal models.Goal, now time.Time) (dto.GoalProgressDto, error) {
+	current, points, deadline, err := goalMetric(db, goal, now)
+	if err != nil {
+		return dto.GoalProgressDto{}, err
+	}
+
+	start := goal.StartValue.Float64()
+	target := goal.Target.Float64()
+	progress := dto.GoalProgressDto{
+		Current:  decimal.FromFloat(current),
+		Percent:  analytics.PercentComplete(start, current, target),
+		Achieved: analytics.GoalReached(start, current, target),
+	}
+
+	var projected *time.Time
+	if progress.Achieved {
+		projected = &now
+	} else {
+		projected = analytics.ProjectCompletion(points, start, target)
+		if projected != nil {
+			formatted := projected.Format("2006-01-02")
+			progress.ProjectedCompletion = &formatted
+		}
+	}
+
+	if deadline != nil {
+		onTrack := projected != nil && !projected.After(*deadline)
+		progress.OnTrack = &onTrack
+	}
+
+	return progress, nil
+}
+
+// goalMetric returns the current value of the goal's metric, the points its
+// trend is fitted through and the day it is due, if any
+func goalMetric(db *gorm.DB, goal models.Goal, now time.Time) (float64, []analytics.GoalPoint, *time.Time, error) {
+	switch goal.Type {
+	case models.OneRepMaxGoal:
+		return oneRepMaxGoalMetric(db, goal, now)
+	case models.SessionsGoal:
+		return sessionsGoalMetric(db, goal, now)
+	default:
+		return bodyweightGoalMetric(goal)
+	}
+}
+
+// oneRepMaxGoalMetric takes the current value from the estimated 1RM PR and
+// the trend from the best estimate of each recent session
+func oneRepMaxGoalMetric(db *gorm.DB, goal models.Goal, now time.Time) (float64, []analytics.GoalPoint, *time.Time, error) {
+	if goal.ExerciseID == nil {
+		return 0, nil, goal.Deadline, nil
+	}
+
+	var current float64
+	err := db.Model(&models.PersonalRecord{}).
+		Select("COALESCE(MAX(value), 0)").
+		Where("user_id = ? AND exercise_id = ? AND type = ?", goal.UserID, *goal.ExerciseID, models.EstimatedOneRepMaxPR).
+		Scan(&current).Error
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	var records []models.Record
+	err = db.Preload("Sets").
+		Where("user_id = ? AND exercise_id = ? AND placeholder = ?", goal.UserID, *goal.ExerciseID, false).
+		Where("COALESCE(date, created_at) >= ?", now.AddDate(0, 0, -goalTrendDays)).
+		Find(&records).Error
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	best := make(map[string]float64)
+	for _, record := range records {
+		day := strength.RecordDay(record, now.Location())
+		for _, set := range record.Sets {
+			estimate := strength.EstimateOneRepMax(set.Weight, set.Reps, strength.Epley).Float64()
+			best[day] = max(best[day], estimate)
+		}
+	}
+
+	points := make([]analytics.GoalPoint, 0, len(best))
+	for day, value := range best {
+		date, _ := time.Parse("2006-01-02", day)
+		points = append(points, analytics.GoalPoint{Date: date, Value: value})
+	}
+	sortGoalPoints(points)
+
+	return current, points, goal.Deadline, nil
+}
+
+// sessionsGoalMetric counts sessions in the running week or month, the trend
+// is the cumulative count over its days and the period's last day is due
+func sessionsGoalMetric(db *gorm.DB, goal models.Goal, now time.Time) (float64, []analytics.GoalPoint, *time.Time, error) {
+	today := calendarDay(now)
+	from := analytics.WeekStart(today)
+	to := from.AddDate(0, 0, 7)
+	if goal.Period != nil && *goal.Period == "month" {
+		from = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		to = from.AddDate(0, 1, 0)
+	}
+
+	localFrom := localMidnight(from, now.Location())
+	localTo := localMidnight(to, now.Location())
+	sessions, err := queryDailySessionCounts(db, goal.UserID, statsRange{From: &localFrom, To: &localTo, Location: now.Location()})
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	var points []analytics.GoalPoint
+	total := 0
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		total += sessions[day.Format("2006-01-02")]
+		points = append(points, analytics.GoalPoint{Date: day, Value: float64(total)})
+	}
+
+	lastDay := to.AddDate(0, 0, -1)
+	return float64(total), points, &lastDay, nil
+}
+
+// bodyweightGoalMetric reads the logged entries, the latest one is current
+func bodyweightGoalMetric(goal models.Goal) (float64, []analytics.GoalPoint, *time.Time, error) {
+	points := make([]analytics.GoalPoint, len(goal.Entries))
+	for i, entry := range goal.Entries {
+		points[i] = analytics.GoalPoint{Date: entry.Date, Value: entry.Value.Float64()}
+	}
+	sortGoalPoints(points)
+
+	current := goal.StartValue.Float64()
+	if len(points) > 0 {
+		current = points[len(points)-1].Value
+	}
+	return current, points, goal.Deadline, nil
+}
+
+func sortGoalPoints(points []analytics.GoalPoint) {
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Date.Before(points[j].Date)
+	})
+}
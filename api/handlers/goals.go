@@ -0,0 +1,521 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/database"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/internal/units"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+)
+
+type GoalHandler struct {
+	db *database.DBInstance
+}
+
+func NewGoalHandler(db *database.DBInstance) *GoalHandler {
+	return &GoalHandler{db: db}
+}
+
+func (h *GoalHandler) GetGoals(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var goals []models.Goal
+	result := h.db.DB.
+		Preload("Exercise").
+		Preload("Entries", orderByDate).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&goals)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	now := time.Now().In(loc)
+	goalsWithProgress := make([]dto.GoalWithProgressDto, len(goals))
+	for i, goal := range goals {
+		progress, err := evaluateGoal(h.db.DB, goal, now)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		goalsWithProgress[i] = goalToUnit(goal, progress, unit)
+	}
+
+	return c.JSON(fiber.Map{
+		"goals": goalsWithProgress,
+		"count": len(goalsWithProgress),
+	})
+}
+
+func (h *GoalHandler) GetGoal(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	goalID, err := c.ParamsInt("id")
+	if err != nil || goalID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid goal ID",
+		})
+	}
+
+	goal, err := findUserGoal(h.db.DB, uint(goalID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Goal not found or doesn't belong to you",
+		})
+	}
+
+	return h.respondWithProgress(c, fiber.StatusOK, goal)
+}
+
+func (h *GoalHandler) CreateGoal(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var goalDto dto.GoalDto
+	if err := c.BodyParser(&goalDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(goalDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	goalDtoToKilograms(&goalDto, unit)
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	deadline, dateError := parseGoalDeadline(goalDto.Deadline, loc)
+	if dateError != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": []utils.ValidationError{*dateError},
+		})
+	}
+
+	goal := models.Goal{UserID: userID}
+	if err := h.applyGoalDto(&goal, goalDto, deadline); err != nil {
+		return respondExerciseCheckError(c, err, "ExerciseID", goalDto.ExerciseID)
+	}
+
+	// Bodyweight goals start their trend from the starting weight
+	if goal.Type == models.BodyweightGoal {
+		goal.Entries = []models.GoalEntry{{Value: goal.StartValue, Date: localMidnight(calendarDay(time.Now().In(loc)), loc)}}
+	}
+
+	if err := h.db.DB.Create(&goal).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	created, err := findUserGoal(h.db.DB, goal.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.respondWithProgress(c, fiber.StatusCreated, created)
+}
+
+func (h *GoalHandler) UpdateGoal(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	goalID, err := c.ParamsInt("id")
+	if err != nil || goalID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid goal ID",
+		})
+	}
+
+	var goalDto dto.GoalDto
+	if err := c.BodyParser(&goalDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(goalDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	goal, err := findUserGoal(h.db.DB, uint(goalID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Goal not found or doesn't belong to you",
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	goalDtoToKilograms(&goalDto, unit)
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	deadline, dateError := parseGoalDeadline(goalDto.Deadline, loc)
+	if dateError != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": []utils.ValidationError{*dateError},
+		})
+	}
+
+	if err := h.applyGoalDto(goal, goalDto, deadline); err != nil {
+		return respondExerciseCheckError(c, err, "ExerciseID", goalDto.ExerciseID)
+	}
+
+	if err := h.db.DB.Omit("Exercise", "Entries").Save(goal).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	updated, err := findUserGoal(h.db.DB, goal.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.respondWithProgress(c, fiber.StatusOK, updated)
+}
+
+func (h *GoalHandler) DeleteGoal(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	goalID, err := c.ParamsInt("id")
+	if err != nil || goalID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid goal ID",
+		})
+	}
+
+	goal, err := findUserGoal(h.db.DB, uint(goalID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Goal not found or doesn't belong to you",
+		})
+	}
+
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("goal_id = ?", goal.ID).Delete(&models.GoalEntry{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(goal).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete goal",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AddGoalEntry logs a value towards a bodyweight goal
+func (h *GoalHandler) AddGoalEntry(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	goalID, err := c.ParamsInt("id")
+	if err != nil || goalID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid goal ID",
+		})
+	}
+
+	var entryDto dto.GoalEntryDto
+	if err := c.BodyParser(&entryDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(entryDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	goal, err := findUserGoal(h.db.DB, uint(goalID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Goal not found or doesn't belong to you",
+		})
+	}
+
+	if goal.Type != models.BodyweightGoal {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only bodyweight goals take entries, others are tracked from training data",
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Entries are plain days at midnight in the user's timezone, today by
+	// default. One far in the future would stay the latest entry and freeze
+	// the goal's current value.
+	entry := models.GoalEntry{
+		GoalID: goal.ID,
+		Value:  units.ToKilograms(entryDto.Value, unit),
+		Date:   localMidnight(calendarDay(time.Now().In(loc)), loc),
+	}
+	if entryDto.Date != nil && *entryDto.Date != "" {
+		parsed, dateError := utils.ParseLogDate("Date", *entryDto.Date, loc, time.Now())
+		if dateError != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Validation failed",
+				"details": []utils.ValidationError{*dateError},
+			})
+		}
+		entry.Date = parsed
+	}
+
+	if err := h.db.DB.Create(&entry).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	updated, err := findUserGoal(h.db.DB, goal.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.respondWithProgress(c, fiber.StatusCreated, updated)
+}
+
+// parseGoalDeadline reads a goal's deadline, a plain day at midnight in loc.
+// Unlike logged dates it may lie any time in the future.
+func parseGoalDeadline(deadline *string, loc *time.Location) (*time.Time, *utils.ValidationError) {
+	if deadline == nil || *deadline == "" {
+		return nil, nil
+	}
+
+	parsed, dateError := utils.ParseDateTime("Deadline", *deadline, loc)
+	if dateError != nil {
+		return nil, dateError
+	}
+	return &parsed, nil
+}
+
+// applyGoalDto copies the dto and its parsed deadline onto the goal. A missing
+// start value is taken from where the user currently stands, an updated goal
+// that still tracks the same thing keeps its own so its progress isn't reset.
+func (h *GoalHandler) applyGoalDto(goal *models.Goal, goalDto dto.GoalDto, deadline *time.Time) error {
+	previousType := goal.Type
+	previousExerciseID := goal.ExerciseID
+
+	goal.Type = models.GoalType(goalDto.Type)
+	goal.Target = goalDto.Target
+	goal.ExerciseID = nil
+	goal.Exercise = nil
+	goal.Period = nil
+	goal.Deadline = deadline
+
+	switch goal.Type {
+	case models.OneRepMaxGoal:
+		if err := checkExercisesExist(h.db.DB, []uint{*goalDto.ExerciseID}); err != nil {
+			return err
+		}
+		goal.ExerciseID = goalDto.ExerciseID
+	case models.SessionsGoal:
+		goal.Period = goalDto.Period
+	}
+
+	if goalDto.StartValue != nil {
+		goal.StartValue = *goalDto.StartValue
+		return nil
+	}
+
+	sameExercise := (previousExerciseID == nil) == (goal.ExerciseID == nil) &&
+		(previousExerciseID == nil || *previousExerciseID == *goal.ExerciseID)
+	if goal.ID != 0 && previousType == goal.Type && sameExercise {
+		return nil
+	}
+
+	// Session goals count up from zero every period
+	goal.StartValue = 0
+	if goal.Type == models.OneRepMaxGoal {
+		loc, err := userLocation(h.db.DB, goal.UserID)
+		if err != nil {
+			return err
+		}
+		current, _, _, err := goalMetric(h.db.DB, *goal, time.Now().In(loc))
+		if err != nil {
+			return err
+		}
+		goal.StartValue = decimal.FromFloat(current)
+	}
+	return nil
+}
+
+func (h *GoalHandler) respondWithProgress(c *fiber.Ctx, status int, goal *models.Goal) error {
+	loc, err := userLocation(h.db.DB, goal.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	progress, err := evaluateGoal(h.db.DB, *goal, time.Now().In(loc))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, goal.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(status).JSON(goalToUnit(*goal, progress, unit))
+}
+
+// isWeightGoal reports whether the goal's values are weights rather than counts
+func isWeightGoal(goalType models.GoalType) bool {
+	return goalType == models.OneRepMaxGoal || goalType == models.BodyweightGoal
+}
+
+func goalDtoToKilograms(goalDto *dto.GoalDto, unit units.Unit) {
+	if !isWeightGoal(models.GoalType(goalDto.Type)) {
+		return
+	}
+	goalDto.Target = units.ToKilograms(goalDto.Target, unit)
+	if goalDto.StartValue != nil {
+		startValue := units.ToKilograms(*goalDto.StartValue, unit)
+		goalDto.StartValue = &startValue
+	}
+}
+
+// goalToUnit pairs a goal with its progress, converting weights for output
+func goalToUnit(goal models.Goal, progress dto.GoalProgressDto, unit units.Unit) dto.GoalWithProgressDto {
+	if isWeightGoal(goal.Type) {
+		goal.Target = units.FromKilograms(goal.Target, unit)
+		goal.StartValue = units.FromKilograms(goal.StartValue, unit)
+		progress.Current = units.FromKilograms(progress.Current, unit)
+
+		entries := make([]models.GoalEntry, len(goal.Entries))
+		for i, entry := range goal.Entries {
+			entry.Value = units.FromKilograms(entry.Value, unit)
+			entries[i] = entry
+		}
+		goal.Entries = entries
+	}
+
+	return dto.GoalWithProgressDto{Goal: goal, Progress: progress}
+}
+
+func findUserGoal(db *gorm.DB, goalID uint, userID uint) (*models.Goal, error) {
+	var goal models.Goal
+	result := db.
+		Preload("Exercise").
+		Preload("Entries", orderByDate).
+		Where("id = ? AND user_id = ?", goalID, userID).
+		First(&goal)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &goal, nil
+}
+
+func orderByDate(db *gorm.DB) *gorm.DB {
+	return db.Order("date ASC, id ASC")
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/database"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/internal/config"
+	"github.com/nagy135/fitness-tracker/models"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+
+	// abandonedAfter is how long a key may stay without a response before its
+	// request is taken to have died with the process, well past the longest
+	// request a client would still wait for
+	abandonedAfter = 2 * time.Minute
+)
+
+type IdempotencyHandler struct {
+	db     *database.DBInstance
+	window time.Duration
+}
+
+func NewIdempotencyHandler(db *database.DBInstance, cfg *config.Config) *IdempotencyHandler {
+	return &IdempotencyHandler{db: db, window: cfg.Server.IdempotencyWindow}
+}
+
+// Replay is a middleware for create endpoints. The first request with an
+// Idempotency-Key runs and its response is stored, retries with the same key
+// within the window get that response again. Requests without a key pass.
+func (h *IdempotencyHandler) Replay(c *fiber.Ctx) error {
+	key := c.Get(headerIdempotencyKey)
+	if key == "" {
+		return c.Next()
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Idempotency-Key is too long",
+		})
+	}
+
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Keys are only remembered for the window, expired ones can be reused
+	expired := time.Now().Add(-h.window)
+	if err := h.db.DB.Where("user_id = ? AND created_at < ?", userID, expired).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// A request that never stored its response doesn't block retries for good
+	abandoned := time.Now().Add(-abandonedAfter)
+	result := h.db.DB.
+		Where("user_id = ? AND key = ? AND status_code IS NULL AND created_at < ?", userID, key, abandoned).
+		Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	idempotencyKey := models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Method:      c.Method(),
+		Path:        c.Path(),
+		RequestHash: requestHash(c),
+	}
+	result = h.db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&idempotencyKey)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	// The key was already used, replay what the first request returned
+	if result.RowsAffected == 0 {
+		var stored models.IdempotencyKey
+		if err := h.db.DB.Where("user_id = ? AND key = ?", userID, key).First(&stored).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return replayIdempotentResponse(c, stored, idempotencyKey)
+	}
+
+	if err := c.Next(); err != nil {
+		h.db.DB.Delete(&idempotencyKey)
+		return err
+	}
+
+	// Server errors aren't remembered, a retry gets to run again
+	status := c.Response().StatusCode()
+	if status >= fiber.StatusInternalServerError {
+		h.db.DB.Delete(&idempotencyKey)
+		return nil
+	}
+
+	// The request already went through, a response that can't be stored only
+	// leaves the key to be taken over once it counts as abandoned
+	err = h.db.DB.Model(&idempotencyKey).Updates(map[string]interface{}{
+		"status_code":  status,
+		"content_type": string(c.Response().Header.ContentType()),
+		"etag":         c.GetRespHeader(fiber.HeaderETag),
+		"body":         append([]byte(nil), c.Response().Body()...),
+	}).Error
+	if err != nil {
+		log.Printf("Error storing the response of idempotency key %d: %v", idempotencyKey.ID, err)
+	}
+
+	return nil
+}
+
+// replayIdempotentResponse sends the stored response of a key again, as long
+// as the retry is the same request the key was first used with
+func replayIdempotentResponse(c *fiber.Ctx, stored models.IdempotencyKey, retry models.IdempotencyKey) error {
+	if stored.Method != retry.Method || stored.Path != retry.Path || stored.RequestHash != retry.RequestHash {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": "Idempotency-Key was already used for a different request",
+		})
+	}
+
+	if stored.StatusCode == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A request with this Idempotency-Key is still being processed",
+		})
+	}
+
+	if stored.ContentType != "" {
+		c.Set(fiber.HeaderContentType, stored.ContentType)
+	}
+	if stored.ETag != "" {
+		c.Set(fiber.HeaderETag, stored.ETag)
+	}
+	c.Set(headerIdempotentReplayed, "true")
+
+	return c.Status(*stored.StatusCode).Send(stored.Body)
+}
+
+// requestHash fingerprints the body of a request
+func requestHash(c *fiber.Ctx) string {
+	sum := sha256.Sum256(c.Body())
+	return hex.EncodeToString(sum[:])
+}
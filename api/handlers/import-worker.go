@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/internal/importer"
+	"github.com/nagy135/fitness-tracker/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// importLookupChunk bounds how many client IDs are looked up per query
+const importLookupChunk = 1000
+
+// importedSession is a session of an export with the records still missing
+type importedSession struct {
+	session   importer.Session
+	clientID  string
+	workoutID *uint
+	entries   []importedEntry
+}
+
+// importMapping is where an exercise name of an export goes, nil leaves it out
+type importMapping struct {
+	exerciseID *uint
+	confirmed  bool
+}
+
+// importedEntry is an exercise of a session that becomes a record
+type importedEntry struct {
+	entry      importer.Entry
+	clientID   string
+	exerciseID uint
+}
+
+// ImportHistory parses an uploaded export and creates its workouts, records
+// and sets. A dry run only stores the summary of what would be created.
+func (w *AsyncWorker) ImportHistory(asyncJobID uint, importID uint) {
+	// A panic would kill the server and leave the job running for good
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("Panic importing history of import %d: %v\n%s", importID, recovered, debug.Stack())
+			w.db.DB.Model(&models.AsyncJob{}).Where("id = ?", asyncJobID).Updates(models.AsyncJob{
+				Status: models.Error,
+				Error:  "Import failed unexpectedly",
+			})
+		}
+	}()
+
+	w.db.DB.Model(&models.AsyncJob{}).Where("id = ?", asyncJobID).Update("status", models.Running)
+
+	summary, err := w.importHistory(importID)
+	if err != nil {
+		log.Printf("Error importing history of import %d: %v", importID, err)
+		w.db.DB.Model(&models.AsyncJob{}).Where("id = ?", asyncJobID).Updates(models.AsyncJob{
+			Status: models.Error,
+			Error:  err.Error(),
+		})
+		return
+	}
+
+	log.Printf("History import %d completed: %d workouts, %d records, %d sets, %d duplicates, %d unmapped records",
+		importID, summary.Workouts, summary.Records, summary.Sets, summary.Duplicates, summary.UnmappedRecords)
+
+	w.db.DB.Model(&models.AsyncJob{}).Where("id = ?", asyncJobID).Update("status", models.Done)
+	log.Printf("Async job %d completed successfully", asyncJobID)
+}
+
+func (w *AsyncWorker) importHistory(importID uint) (models.ImportSummary, error) {
+	var historyImport models.Import
+	if err := w.db.DB.First(&historyImport, importID).Error; err != nil {
+		return models.ImportSummary{}, err
+	}
+	userID := historyImport.UserID
+
+	unit, err := userUnit(w.db.DB, userID)
+	if err != nil {
+		return models.ImportSummary{}, err
+	}
+
+	loc, err := userLocation(w.db.DB, userID)
+	if err != nil {
+		return models.ImportSummary{}, err
+	}
+
+	parsed, err := importer.Parse(importer.Source(historyImport.Source), bytes.NewReader(historyImport.Data), loc, unit)
+	if err != nil {
+		return models.ImportSummary{}, err
+	}
+
+	var names []string
+	for _, session := range parsed.Sessions {
+		for _, entry := range session.Entries {
+			names = append(names, entry.Exercise)
+		}
+	}
+
+	mappings, err := importMappings(w.db.DB, userID, historyImport.Source, names)
+	if err != nil {
+		return models.ImportSummary{}, err
+	}
+
+	sessions, summary, err := planImport(w.db.DB, historyImport, parsed, mappings)
+	if err != nil {
+		return models.ImportSummary{}, err
+	}
+
+	if !historyImport.DryRun {
+		err := w.db.DB.Transaction(func(tx *gorm.DB) error {
+			return createImportedSessions(tx, userID, sessions)
+		})
+		if err != nil {
+			return models.ImportSummary{}, err
+		}
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return models.ImportSummary{}, err
+	}
+	if err := w.db.DB.Model(&historyImport).Update("summary", string(summaryJSON)).Error; err != nil {
+		return models.ImportSummary{}, err
+	}
+
+	return summary, nil
+}
+
+// importMappings returns where each exercise name goes. Confirmed mappings
+// are used as they are, every other name gets the closest catalog exercise
+// suggested again so catalog changes are picked up.
+func importMappings(db *gorm.DB, userID uint, source string, names []string) (map[string]importMapping, error) {
+	names = uniqueNames(names)
+	mappings := make(map[string]importMapping, len(names))
+	if len(names) == 0 {
+		return mappings, nil
+	}
+
+	var confirmed []models.ExerciseMapping
+	result := db.Where("user_id = ? AND source = ? AND confirmed = ? AND name IN ?", userID, source, true, names).Find(&confirmed)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, mapping := range confirmed {
+		mappings[mapping.Name] = importMapping{exerciseID: mapping.ExerciseID, confirmed: true}
+	}
+
+	var candidates []importer.Candidate
+	if err := db.Model(&models.Exercise{}).Select("id, name").Scan(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	var suggestions []models.ExerciseMapping
+	for _, name := range names {
+		if _, ok := mappings[name]; ok {
+			continue
+		}
+
+		suggestion := models.ExerciseMapping{UserID: userID, Source: source, Name: name}
+		if match, ok := importer.BestMatch(name, candidates); ok {
+			exerciseID := match.ExerciseID
+			suggestion.ExerciseID = &exerciseID
+			suggestion.Score = match.Score
+		}
+
+		suggestions = append(suggestions, suggestion)
+		mappings[name] = importMapping{exerciseID: suggestion.ExerciseID}
+	}
+
+	if len(suggestions) > 0 {
+		result := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "source"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"exercise_id", "score", "updated_at"}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Eq{Column: "exercise_mappings.confirmed", Value: false}}},
+		}).Create(&suggestions)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+	}
+
+	return mappings, nil
+}
+
+// planImport works out which records of an export are still missing. Imported
+// rows get client IDs derived from the export, so running an import again,
+// or importing an overlapping export, only adds what isn't there yet. Only a
+// dry run goes by suggested mappings, a real run leaves exercises the user
+// hasn't confirmed out until they are.
+func planImport(db *gorm.DB, historyImport models.Import, parsed importer.Result, mappings map[string]importMapping) ([]importedSession, models.ImportSummary, error) {
+	summary := models.ImportSummary{SkippedSets: parsed.SkippedSets}
+	userID := historyImport.UserID
+
+	sessions := make([]importedSession, len(parsed.Sessions))
+	var workoutClientIDs, recordClientIDs []string
+	for i, session := range parsed.Sessions {
+		workoutClientID := importClientID(userID, historyImport.Source, "workout", session.Start.UTC().Format(time.RFC3339), session.Label)
+		sessions[i] = importedSession{session: session, clientID: workoutClientID}
+		workoutClientIDs = append(workoutClientIDs, workoutClientID)
+
+		for _, entry := range session.Entries {
+			recordClientIDs = append(recordClientIDs, importClientID(userID, historyImport.Source, "record", workoutClientID, entry.Exercise))
+		}
+
+		start := session.Start
+		if summary.From == nil || start.Before(*summary.From) {
+			summary.From = &start
+		}
+		if summary.To == nil || start.After(*summary.To) {
+			summary.To = &start
+		}
+	}
+
+	// Soft deleted rows still hold their client ID, what the user deleted
+	// after an earlier import isn't brought back
+	var workouts []models.Workout
+	for _, chunk := range chunkStrings(workoutClientIDs, importLookupChunk) {
+		var found []models.Workout
+		if err := db.Unscoped().Select("id, client_id, deleted_at").Where("user_id = ? AND client_id IN ?", userID, chunk).Find(&found).Error; err != nil {
+			return nil, models.ImportSummary{}, err
+		}
+		workouts = append(workouts, found...)
+	}
+	existingWorkouts := make(map[string]models.Workout, len(workouts))
+	for _, workout := range workouts {
+		existingWorkouts[*workout.ClientID] = workout
+	}
+
+	existingRecords := make(map[string]bool, len(recordClientIDs))
+	for _, chunk := range chunkStrings(recordClientIDs, importLookupChunk) {
+		var found []string
+		if err := db.Unscoped().Model(&models.Record{}).Where("user_id = ? AND client_id IN ?", userID, chunk).Pluck("client_id", &found).Error; err != nil {
+			return nil, models.ImportSummary{}, err
+		}
+		for _, clientID := range found {
+			existingRecords[clientID] = true
+		}
+	}
+
+	exercises := make(map[string]int)
+	var planned []importedSession
+	for _, session := range sessions {
+		workout, workoutExists := existingWorkouts[session.clientID]
+		if workoutExists {
+			workoutID := workout.ID
+			session.workoutID = &workoutID
+		}
+
+		for _, entry := range session.session.Entries {
+			index, ok := exercises[entry.Exercise]
+			if !ok {
+				index = len(summary.Exercises)
+				exercises[entry.Exercise] = index
+				summary.Exercises = append(summary.Exercises, models.ImportExerciseSummary{
+					Name:       entry.Exercise,
+					ExerciseID: mappings[entry.Exercise].exerciseID,
+					Confirmed:  mappings[entry.Exercise].confirmed,
+				})
+			}
+			summary.Exercises[index].Records++
+
+			mapping := mappings[entry.Exercise]
+			exerciseID := mapping.exerciseID
+			if exerciseID == nil || (!mapping.confirmed && !historyImport.DryRun) {
+				summary.UnmappedRecords++
+				continue
+			}
+
+			recordClientID := importClientID(userID, historyImport.Source, "record", session.clientID, entry.Exercise)
+			if existingRecords[recordClientID] || (workoutExists && workout.DeletedAt.Valid) {
+				summary.Duplicates++
+				continue
+			}
+
+			session.entries = append(session.entries, importedEntry{
+				entry:      entry,
+				clientID:   recordClientID,
+				exerciseID: *exerciseID,
+			})
+			summary.Records++
+			summary.Sets += len(entry.Sets)
+		}
+
+		if len(session.entries) == 0 {
+			continue
+		}
+		if !workoutExists {
+			summary.Workouts++
+		}
+		planned = append(planned, session)
+	}
+
+	return planned, summary, nil
+}
+
+// createImportedSessions writes the planned workouts, records and sets, then
+// rebuilds the PR history of the touched exercises since imported records
+// usually predate the ones already logged
+func createImportedSessions(tx *gorm.DB, userID uint, sessions []importedSession) error {
+	var exerciseIDs []uint
+	for _, session := range sessions {
+		position := 0
+		if session.workoutID != nil {
+			next, err := nextWorkoutPosition(tx, *session.workoutID)
+			if err != nil {
+				return err
+			}
+			position = next
+		} else {
+			start := session.session.Start
+			clientID := session.clientID
+			workout := models.Workout{
+				ClientID: &clientID,
+				UserID:   userID,
+				Label:    session.session.Label,
+				Date:     &start,
+			}
+			if session.session.End != nil {
+				workout.StartedAt = &start
+				workout.FinishedAt = session.session.End
+			}
+			if err := tx.Create(&workout).Error; err != nil {
+				return err
+			}
+			session.workoutID = &workout.ID
+		}
+
+		for _, entry := range session.entries {
+			date := session.session.Start
+			clientID := entry.clientID
+			record := models.Record{
+				ClientID:   &clientID,
+				ExerciseID: entry.exerciseID,
+				UserID:     userID,
+				Date:       &date,
+				WorkoutID:  session.workoutID,
+				Position:   position,
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+			position++
+
+			sets := make([]models.Set, len(entry.entry.Sets))
+			for i, set := range entry.entry.Sets {
+				sets[i] = models.Set{
+					Reps:     set.Reps,
+					Weight:   set.Weight,
+					RecordID: record.ID,
+				}
+			}
+			if err := tx.Create(&sets).Error; err != nil {
+				return err
+			}
+
+			exerciseIDs = append(exerciseIDs, entry.exerciseID)
+		}
+	}
+
+	return recomputeExercisePRs(tx, userID, uniqueIDs(exerciseIDs))
+}
+
+// importClientID derives a stable client ID for an imported row from the
+// parts that identify it in the export
+func importClientID(userID uint, source string, kind string, parts ...string) string {
+	hash := sha1.Sum([]byte(strings.Join(append([]string{strconv.FormatUint(uint64(userID), 10), source, kind}, parts...), "|")))
+	return "import-" + hex.EncodeToString(hash[:])
+}
+
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var unique []string
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+func chunkStrings(values []string, size int) [][]string {
+	var chunks [][]string
+	for len(values) > size {
+		chunks = append(chunks, values[:size])
+		values = values[size:]
+	}
+	if len(values) > 0 {
+		chunks = append(chunks, values)
+	}
+	return chunks
+}
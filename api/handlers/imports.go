@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/database"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errImportRunning is returned when an import is started while it still runs
+var errImportRunning = errors.New("import is already running")
+
+type ImportHandler struct {
+	db     *database.DBInstance
+	worker *AsyncWorker
+}
+
+func NewImportHandler(db *database.DBInstance) *ImportHandler {
+	return &ImportHandler{
+		db:     db,
+		worker: NewAsyncWorker(db),
+	}
+}
+
+func (h *ImportHandler) GetImports(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var imports []models.Import
+	result := h.db.DB.Omit("data").Preload("AsyncJob").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&imports)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"imports": imports,
+		"count":   len(imports),
+	})
+}
+
+// GetImport returns an import with the summary of its latest run and the
+// mappings of the exercise names it contains
+func (h *ImportHandler) GetImport(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	importID, err := c.ParamsInt("id")
+	if err != nil || importID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid import ID",
+		})
+	}
+
+	historyImport, err := findUserImport(h.db.DB, uint(importID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Import not found or doesn't belong to you",
+		})
+	}
+
+	mappings := []models.ExerciseMapping{}
+	if historyImport.Summary != nil && len(historyImport.Summary.Exercises) > 0 {
+		names := make([]string, len(historyImport.Summary.Exercises))
+		for i, exercise := range historyImport.Summary.Exercises {
+			names[i] = exercise.Name
+		}
+
+		result := h.db.DB.Preload("Exercise").
+			Where("user_id = ? AND source = ? AND name IN ?", userID, historyImport.Source, names).
+			Order("confirmed ASC, score ASC, name ASC").
+			Find(&mappings)
+		if result.Error != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": result.Error.Error(),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"import":   historyImport,
+		"mappings": mappings,
+	})
+}
+
+// CreateImport uploads a CSV export of Strong, Hevy or FitNotes and starts a
+// job reading it. By default it's a dry run that only previews the import and
+// suggests exercise mappings, POST /imports/:id/run imports it for real once
+// they are confirmed. Uploaded as a real run, only exercises mapped by earlier
+// imports from the same app are imported.
+func (h *ImportHandler) CreateImport(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var importDto dto.ImportDto
+	if err := c.BodyParser(&importDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse form",
+		})
+	}
+
+	if errors := utils.ValidateStruct(importDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Missing CSV file in the file field",
+		})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if len(data) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The file is empty",
+		})
+	}
+
+	historyImport := models.Import{
+		UserID:   userID,
+		Source:   importDto.Source,
+		FileName: fileHeader.Filename,
+		Data:     data,
+		DryRun:   importDto.DryRun == nil || *importDto.DryRun,
+	}
+
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&historyImport).Error; err != nil {
+			return err
+		}
+		return startImportJob(tx, &historyImport)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	go h.worker.ImportHistory(*historyImport.AsyncJobID, historyImport.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(historyImport)
+}
+
+// UpdateImportMappings confirms where exercise names of an import go.
+// Confirmed mappings are kept for every later import from the same app.
+func (h *ImportHandler) UpdateImportMappings(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	importID, err := c.ParamsInt("id")
+	if err != nil || importID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid import ID",
+		})
+	}
+
+	var mappingsDto dto.ImportMappingsDto
+	if err := c.BodyParser(&mappingsDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(mappingsDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	historyImport, err := findUserImport(h.db.DB, uint(importID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Import not found or doesn't belong to you",
+		})
+	}
+
+	var exerciseIDs []uint
+	for _, mappingDto := range mappingsDto.Mappings {
+		if mappingDto.ExerciseID != nil {
+			exerciseIDs = append(exerciseIDs, *mappingDto.ExerciseID)
+		}
+	}
+
+	names := make([]string, len(mappingsDto.Mappings))
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if len(exerciseIDs) > 0 {
+			if err := checkExercisesExist(tx, exerciseIDs); err != nil {
+				return err
+			}
+		}
+
+		for i, mappingDto := range mappingsDto.Mappings {
+			mapping := models.ExerciseMapping{
+				UserID: userID,
+				Source: historyImport.Source,
+				Name:   mappingDto.Name,
+			}
+			if err := tx.Where(mapping).FirstOrCreate(&mapping).Error; err != nil {
+				return err
+			}
+
+			// A mapping picked by hand is as good as an exact match
+			score := 0.0
+			if mappingDto.ExerciseID != nil {
+				score = 1
+			}
+			result := tx.Model(&mapping).Select("exercise_id", "score", "confirmed").Updates(models.ExerciseMapping{
+				ExerciseID: mappingDto.ExerciseID,
+				Score:      score,
+				Confirmed:  true,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+
+			names[i] = mappingDto.Name
+		}
+		return nil
+	})
+	if errors.Is(err, errExercisesNotFound) {
+		return respondExerciseCheckError(c, err, "Mappings", exerciseIDs)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var mappings []models.ExerciseMapping
+	result := h.db.DB.Preload("Exercise").
+		Where("user_id = ? AND source = ? AND name IN ?", userID, historyImport.Source, names).
+		Order("name ASC").
+		Find(&mappings)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"mappings": mappings,
+		"count":    len(mappings),
+	})
+}
+
+// RunImport starts the import again, as a dry run to preview changed mappings
+// or for real. Records imported by an earlier run are skipped.
+func (h *ImportHandler) RunImport(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	importID, err := c.ParamsInt("id")
+	if err != nil || importID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid import ID",
+		})
+	}
+
+	var runDto dto.ImportRunDto
+	if err := c.BodyParser(&runDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	historyImport, err := findUserImport(h.db.DB, uint(importID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Import not found or doesn't belong to you",
+		})
+	}
+
+	// Exercises are only imported for real where the user confirmed them
+	if !runDto.DryRun {
+		if historyImport.Summary == nil {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": "Preview the import with a dry run first",
+			})
+		}
+
+		unconfirmed, err := unconfirmedImportNames(h.db.DB, historyImport)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if len(unconfirmed) > 0 {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":       "Confirm where every exercise goes before importing for real",
+				"unconfirmed": unconfirmed,
+			})
+		}
+	}
+
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the import keeps two runs from starting at once
+		var locked models.Import
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Omit("data").First(&locked, historyImport.ID).Error; err != nil {
+			return err
+		}
+
+		if locked.AsyncJobID != nil {
+			var asyncJob models.AsyncJob
+			if err := tx.First(&asyncJob, *locked.AsyncJobID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if asyncJob.Status == models.Pending || asyncJob.Status == models.Running {
+				return errImportRunning
+			}
+		}
+
+		historyImport.DryRun = runDto.DryRun
+		if err := tx.Model(historyImport).Update("dry_run", runDto.DryRun).Error; err != nil {
+			return err
+		}
+		return startImportJob(tx, historyImport)
+	})
+	if errors.Is(err, errImportRunning) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Import is already running, wait for its job to finish",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	go h.worker.ImportHistory(*historyImport.AsyncJobID, historyImport.ID)
+
+	return c.Status(fiber.StatusAccepted).JSON(historyImport)
+}
+
+// unconfirmedImportNames lists the exercise names of an import's latest
+// preview whose mapping the user hasn't confirmed
+func unconfirmedImportNames(db *gorm.DB, historyImport *models.Import) ([]string, error) {
+	names := make([]string, len(historyImport.Summary.Exercises))
+	for i, exercise := range historyImport.Summary.Exercises {
+		names[i] = exercise.Name
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var confirmed []string
+	result := db.Model(&models.ExerciseMapping{}).
+		Where("user_id = ? AND source = ? AND confirmed = ? AND name IN ?", historyImport.UserID, historyImport.Source, true, names).
+		Pluck("name", &confirmed)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	isConfirmed := make(map[string]bool, len(confirmed))
+	for _, name := range confirmed {
+		isConfirmed[name] = true
+	}
+
+	var unconfirmed []string
+	for _, name := range names {
+		if !isConfirmed[name] {
+			unconfirmed = append(unconfirmed, name)
+		}
+	}
+	return unconfirmed, nil
+}
+
+// startImportJob creates a pending job for the next run of an import
+func startImportJob(tx *gorm.DB, historyImport *models.Import) error {
+	asyncJob := models.AsyncJob{
+		Type:   models.ImportHistory,
+		Status: models.Pending,
+	}
+	if err := tx.Create(&asyncJob).Error; err != nil {
+		return err
+	}
+
+	historyImport.AsyncJobID = &asyncJob.ID
+	historyImport.AsyncJob = &asyncJob
+	return tx.Model(historyImport).Update("async_job_id", asyncJob.ID).Error
+}
+
+// findUserImport loads an import of the user with its latest job, without the file
+func findUserImport(db *gorm.DB, importID uint, userID uint) (*models.Import, error) {
+	var historyImport models.Import
+	result := db.Omit("data").Preload("AsyncJob").
+		Where("id = ? AND user_id = ?", importID, userID).
+		First(&historyImport)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &historyImport, nil
+}
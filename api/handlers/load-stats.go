@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/analytics"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/utils"
+)
+
+// defaultLoadDays is how many days GetLoadStats reports when no from is given
+const defaultLoadDays = 28
+
+// maxLoadDays bounds the range GetLoadStats computes, every day is walked
+const maxLoadDays = 3 * 366
+
+// GetLoadStats returns acute and chronic load, their ratio, monotony and strain
+// per day, with warnings where they exceed the thresholds
+func (h *StatsHandler) GetLoadStats(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var loadQuery dto.LoadQueryDto
+	if err := c.QueryParser(&loadQuery); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse query",
+		})
+	}
+
+	if errors := utils.ValidateStruct(loadQuery); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Days are calendar days of the user's timezone
+	to := calendarDay(time.Now().In(loc))
+	if loadQuery.To != "" {
+		to, _ = time.Parse("2006-01-02", loadQuery.To)
+	}
+	from := to.AddDate(0, 0, -(defaultLoadDays - 1))
+	if loadQuery.From != "" {
+		from, _ = time.Parse("2006-01-02", loadQuery.From)
+	}
+	if from.After(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "from must not be after to",
+		})
+	}
+	if to.Sub(from) >= maxLoadDays*24*time.Hour {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Range must not span more than %d days", maxLoadDays),
+		})
+	}
+
+	thresholds := analytics.Thresholds{
+		ACWR:     analytics.DefaultACWRThreshold,
+		Monotony: analytics.DefaultMonotonyThreshold,
+	}
+	if loadQuery.ACWRThreshold != nil {
+		thresholds.ACWR = *loadQuery.ACWRThreshold
+	}
+	if loadQuery.MonotonyThreshold != nil {
+		thresholds.Monotony = *loadQuery.MonotonyThreshold
+	}
+	if loadQuery.StrainThreshold != nil {
+		thresholds.Strain = *loadQuery.StrainThreshold
+	}
+
+	// The chronic window of the first day reaches back before from
+	chronicFrom := localMidnight(from.AddDate(0, 0, -(analytics.ChronicDays-1)), loc)
+	exclusiveTo := localMidnight(to.AddDate(0, 0, 1), loc)
+	volumes, err := queryDailyVolumes(h.db.DB, userID, statsRange{From: &chronicFrom, To: &exclusiveTo, Location: loc})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	volumesToUnit(volumes, unit)
+
+	days := analytics.ComputeLoad(volumes, from, to, thresholds)
+
+	return c.JSON(fiber.Map{
+		"load":    days,
+		"current": days[len(days)-1],
+		"thresholds": fiber.Map{
+			"acwr":     thresholds.ACWR,
+			"monotony": thresholds.Monotony,
+			"strain":   thresholds.Strain,
+		},
+	})
+}
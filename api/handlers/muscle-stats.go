@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/internal/units"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+)
+
+const (
+	// defaultSecondaryWeight is how much a set counts towards secondary muscles
+	defaultSecondaryWeight = 0.5
+	// defaultHardSetPercent is the share of the day's top weight of an exercise
+	// a set needs to count as a hard set rather than a warm-up
+	defaultHardSetPercent = 0.7
+)
+
+// GetMuscleStats aggregates volume, hard sets and training frequency per muscle
+// over weekly or monthly buckets. Secondary muscles get a weighted share.
+func (h *StatsHandler) GetMuscleStats(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var muscleQuery dto.MuscleStatsQueryDto
+	if err := c.QueryParser(&muscleQuery); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse query",
+		})
+	}
+
+	if errors := utils.ValidateStruct(muscleQuery); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	period := muscleQuery.Period
+	if period == "" {
+		period = "week"
+	}
+
+	secondaryWeight := defaultSecondaryWeight
+	if muscleQuery.SecondaryWeight != nil {
+		secondaryWeight = *muscleQuery.SecondaryWeight
+	}
+
+	hardSetPercent := defaultHardSetPercent
+	if muscleQuery.HardSetPercent != nil {
+		hardSetPercent = *muscleQuery.HardSetPercent
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	statsRange := newStatsRange(muscleQuery.From, muscleQuery.To, loc)
+	rangeSQL, rangeArgs := statsRange.where("COALESCE(r.date, r.created_at)")
+
+	// Per bucket, day and exercise totals, muscles are resolved afterwards.
+	// Buckets and days are those of the user's timezone.
+	var rows []struct {
+		Bucket     string
+		Day        string
+		ExerciseID uint
+		Volume     decimal.Decimal
+		HardSets   int
+	}
+	timezone := statsRange.timezone()
+	args := append([]any{period, hardSetPercent, timezone, timezone, userID}, rangeArgs...)
+	err = h.db.DB.Raw(`
+		SELECT TO_CHAR(DATE_TRUNC(?, at), 'YYYY-MM-DD') AS bucket,
+			TO_CHAR(at, 'YYYY-MM-DD') AS day,
+			exercise_id,
+			ROUND(SUM(weight * reps * multiplier), 3) AS volume,
+			COUNT(*) FILTER (WHERE weight >= top_weight * ?) AS hard_sets
+		FROM (
+			SELECT COALESCE(r.date, r.created_at) AT TIME ZONE ? AS at, r.exercise_id, s.weight, s.reps,
+				`+multiplierSQL+` AS multiplier,
+				MAX(s.weight) OVER (PARTITION BY r.exercise_id, DATE(COALESCE(r.date, r.created_at) AT TIME ZONE ?)) AS top_weight
+			FROM records r
+			JOIN exercises e ON e.id = r.exercise_id
+			JOIN sets s ON s.record_id = r.id AND s.deleted_at IS NULL
+			WHERE r.user_id = ? AND r.deleted_at IS NULL AND r.placeholder = false`+rangeSQL+`
+		) set_rows
+		GROUP BY bucket, day, exercise_id
+	`, args...).Scan(&rows).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var exerciseIDs []uint
+	for _, row := range rows {
+		exerciseIDs = append(exerciseIDs, row.ExerciseID)
+	}
+
+	var exercises []models.Exercise
+	if len(exerciseIDs) > 0 {
+		if err := h.db.DB.Unscoped().Select("id, primary_muscles, secondary_muscles").Where("id IN ?", uniqueIDs(exerciseIDs)).Find(&exercises).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	exercisesByID := make(map[uint]models.Exercise, len(exercises))
+	for _, exercise := range exercises {
+		exercisesByID[exercise.ID] = exercise
+	}
+
+	type muscleTotals struct {
+		volume   decimal.Decimal
+		hardSets float64
+		days     map[string]bool
+	}
+	buckets := make(map[string]map[string]*muscleTotals)
+
+	add := func(bucket string, muscle string, day string, volume decimal.Decimal, hardSets float64) {
+		if buckets[bucket] == nil {
+			buckets[bucket] = make(map[string]*muscleTotals)
+		}
+		totals, ok := buckets[bucket][muscle]
+		if !ok {
+			totals = &muscleTotals{days: make(map[string]bool)}
+			buckets[bucket][muscle] = totals
+		}
+		totals.volume += volume
+		totals.hardSets += hardSets
+		totals.days[day] = true
+	}
+
+	for _, row := range rows {
+		exercise := exercisesByID[row.ExerciseID]
+		for _, muscle := range exercise.PrimaryMuscles {
+			add(row.Bucket, muscle, row.Day, row.Volume, float64(row.HardSets))
+		}
+		if secondaryWeight > 0 {
+			for _, muscle := range exercise.SecondaryMuscles {
+				add(row.Bucket, muscle, row.Day, row.Volume.MulFloat(secondaryWeight), float64(row.HardSets)*secondaryWeight)
+			}
+		}
+	}
+
+	stats := []dto.MuscleStatsBucketDto{}
+	for start, muscles := range buckets {
+		bucket := dto.MuscleStatsBucketDto{Start: start}
+		for muscle, totals := range muscles {
+			bucket.Muscles = append(bucket.Muscles, dto.MuscleBucketStatsDto{
+				Muscle:    muscle,
+				Volume:    units.FromKilograms(totals.volume, unit),
+				HardSets:  decimal.RoundRatio(totals.hardSets),
+				Frequency: len(totals.days),
+			})
+		}
+		sort.Slice(bucket.Muscles, func(i, j int) bool {
+			return bucket.Muscles[i].Volume > bucket.Muscles[j].Volume
+		})
+		stats = append(stats, bucket)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Start < stats[j].Start
+	})
+
+	return c.JSON(fiber.Map{
+		"period":          period,
+		"secondaryWeight": secondaryWeight,
+		"hardSetPercent":  hardSetPercent,
+		"buckets":         stats,
+		"count":           len(stats),
+	})
+}
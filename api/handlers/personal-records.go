@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/internal/strength"
+	"github.com/nagy135/fitness-tracker/models"
+	"gorm.io/gorm"
+)
+
+// GetExercisePRHistory lists every PR of an exercise in the order they were set
+func (h *RecordHandler) GetExercisePRHistory(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	exerciseID, err := c.ParamsInt("exerciseId")
+	if err != nil || exerciseID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid exercise ID",
+		})
+	}
+
+	query := h.db.DB.Where("user_id = ? AND exercise_id = ?", userID, exerciseID)
+	if prType := c.Query("type"); prType != "" {
+		query = query.Where("type = ?", prType)
+	}
+
+	var history []models.PersonalRecord
+	result := query.Order("date ASC, id ASC").Find(&history)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	personalRecordsToUnit(history, unit)
+
+	return c.JSON(fiber.Map{
+		"history": history,
+		"count":   len(history),
+	})
+}
+
+// storeRecordPRs detects the PRs a freshly written record sets against the
+// user's earlier records of the exercise and saves them. A record written
+// before others of the exercise changes what they beat, so their history is
+// rebuilt instead. Must run inside the transaction that wrote the record,
+// after its sets were created.
+func storeRecordPRs(tx *gorm.DB, recordID uint) ([]models.PersonalRecord, error) {
+	// PRs of a rewritten record are detected again from scratch
+	if err := tx.Unscoped().Where("record_id = ?", recordID).Delete(&models.PersonalRecord{}).Error; err != nil {
+		return nil, err
+	}
+
+	var record models.Record
+	if err := tx.Preload("Exercise").Preload("Sets").First(&record, recordID).Error; err != nil {
+		return nil, err
+	}
+
+	if record.Placeholder {
+		return []models.PersonalRecord{}, nil
+	}
+
+	// Records are ordered like the history is replayed, by day, then creation
+	ordered := "(COALESCE(date, created_at), created_at, id)"
+	position := []any{strength.RecordTime(record), record.CreatedAt, record.ID}
+
+	var later int64
+	result := tx.Model(&models.Record{}).
+		Where("user_id = ? AND exercise_id = ? AND placeholder = ?", record.UserID, record.ExerciseID, false).
+		Where(ordered+" > (?, ?, ?)", position...).
+		Count(&later)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if later > 0 {
+		return refreshRecordPRs(tx, record.UserID, record.ID, record.ExerciseID)
+	}
+
+	var prior []models.Record
+	result = tx.Preload("Sets").
+		Where("user_id = ? AND exercise_id = ? AND placeholder = ?", record.UserID, record.ExerciseID, false).
+		Where(ordered+" < (?, ?, ?)", position...).
+		Find(&prior)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	loc, err := userLocation(tx, record.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	detected := strength.DetectPersonalRecords(prior, record, record.Exercise, loc)
+	if len(detected) == 0 {
+		return []models.PersonalRecord{}, nil
+	}
+
+	if err := tx.Create(&detected).Error; err != nil {
+		return nil, err
+	}
+
+	return detected, nil
+}
+
+// refreshRecordPRs rebuilds the PR history of the given exercises after a
+// record was edited, moved in time or onto another exercise, and returns the
+// PRs the record holds now
+func refreshRecordPRs(tx *gorm.DB, userID uint, recordID uint, exerciseIDs ...uint) ([]models.PersonalRecord, error) {
+	if err := recomputeExercisePRs(tx, userID, uniqueIDs(exerciseIDs)); err != nil {
+		return nil, err
+	}
+
+	prs := []models.PersonalRecord{}
+	if err := tx.Where("record_id = ?", recordID).Order("id ASC").Find(&prs).Error; err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
+
+// withPRFlags marks the sets of a record with the PR types they achieved
+func withPRFlags(record models.Record, prs []models.PersonalRecord) dto.RecordWithPRsDto {
+	for i := range record.Sets {
+		for _, pr := range prs {
+			if pr.SetID != nil && *pr.SetID == record.Sets[i].ID {
+				record.Sets[i].PersonalRecords = append(record.Sets[i].PersonalRecords, pr.Type)
+			}
+		}
+	}
+
+	return dto.RecordWithPRsDto{
+		Record:          record,
+		PersonalRecords: prs,
+	}
+}
+
+// recomputeExercisePRs rebuilds a user's PR history of the given exercises by
+// replaying their records in date order, for when records were written into
+// the past and every later PR may have changed
+func recomputeExercisePRs(tx *gorm.DB, userID uint, exerciseIDs []uint) error {
+	if len(exerciseIDs) == 0 {
+		return nil
+	}
+
+	// PRs are derived from the records, the old ones aren't kept around
+	if err := tx.Unscoped().Where("user_id = ? AND exercise_id IN ?", userID, exerciseIDs).Delete(&models.PersonalRecord{}).Error; err != nil {
+		return err
+	}
+
+	var records []models.Record
+	result := tx.Preload("Exercise").Preload("Sets").
+		Where("user_id = ? AND exercise_id IN ? AND placeholder = ?", userID, exerciseIDs, false).
+		Order("exercise_id, COALESCE(date, created_at), created_at, id").
+		Find(&records)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	loc, err := userLocation(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	var prior []models.Record
+	for i, record := range records {
+		if i > 0 && records[i-1].ExerciseID != record.ExerciseID {
+			prior = nil
+		}
+
+		detected := strength.DetectPersonalRecords(prior, record, record.Exercise, loc)
+		if len(detected) > 0 {
+			if err := tx.Create(&detected).Error; err != nil {
+				return err
+			}
+		}
+
+		prior = append(prior, record)
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/models"
+	"gorm.io/gorm"
+)
+
+// programWeightIncrement is the step prescribed weights get rounded to
+var programWeightIncrement = decimal.FromFloat(2.5)
+
+// prescribedWeightTolerance is how many kilograms a set may fall short of the
+// prescribed weight and still count. Prescriptions are rounded to plates in
+// the user's unit, a 5 lb step is about 2.27 kg, so half of it is allowed.
+const prescribedWeightTolerance = 1.1
+
+var errProgramNotFound = errors.New("Program not found or doesn't belong to you")
+
+var errProgramDayCompleted = errors.New("Workout has already completed a day of this program")
+
+var errProgramDayMismatch = errors.New("Workout wasn't started for the day this program expects")
+
+type programDayRef struct {
+	week int
+	day  models.ProgramDay
+}
+
+// programDays flattens the weeks of a program into its training days in order
+func programDays(program *models.Program) []programDayRef {
+	var days []programDayRef
+	for _, week := range program.Weeks {
+		for _, day := range week.Days {
+			days = append(days, programDayRef{week: week.Number, day: day})
+		}
+	}
+	return days
+}
+
+// prescribeNextDay resolves the day the program cursor points at into
+// concrete sets, reps and weights
+func prescribeNextDay(program *models.Program) *dto.ProgramTodayDto {
+	days := programDays(program)
+	if len(days) == 0 {
+		return nil
+	}
+
+	ref := days[program.NextDayIndex%len(days)]
+
+	trainingMaxes := make(map[uint]decimal.Decimal, len(program.TrainingMaxes))
+	for _, trainingMax := range program.TrainingMaxes {
+		trainingMaxes[trainingMax.ExerciseID] = trainingMax.Weight
+	}
+
+	prescriptions := make(map[uint]models.ProgramPrescription, len(ref.day.Prescriptions))
+	for _, prescription := range ref.day.Prescriptions {
+		prescriptions[prescription.ExerciseID] = prescription
+	}
+
+	today := &dto.ProgramTodayDto{
+		ProgramID:         program.ID,
+		Cycle:             program.Cycle,
+		Week:              ref.week,
+		ProgramDayID:      ref.day.ID,
+		Label:             ref.day.Label,
+		WorkoutTemplateID: ref.day.WorkoutTemplateID,
+		Exercises:         []dto.PrescribedExerciseDto{},
+	}
+
+	// Template exercises come first in template order, prescriptions override their targets
+	used := make(map[uint]bool)
+	for _, templateExercise := range ref.day.WorkoutTemplate.Exercises {
+		exercise := dto.PrescribedExerciseDto{
+			ExerciseID:   templateExercise.ExerciseID,
+			ExerciseName: templateExercise.Exercise.Name,
+			Sets:         templateExercise.TargetSets,
+			Reps:         templateExercise.TargetReps,
+			Weight:       templateExercise.TargetWeight,
+		}
+
+		if prescription, ok := prescriptions[templateExercise.ExerciseID]; ok {
+			applyPrescription(&exercise, prescription, trainingMaxes)
+			used[templateExercise.ExerciseID] = true
+		}
+
+		today.Exercises = append(today.Exercises, exercise)
+	}
+
+	// Prescriptions for exercises outside the template are appended at the end
+	for _, prescription := range ref.day.Prescriptions {
+		if used[prescription.ExerciseID] {
+			continue
+		}
+
+		exercise := dto.PrescribedExerciseDto{
+			ExerciseID:   prescription.ExerciseID,
+			ExerciseName: prescription.Exercise.Name,
+		}
+		applyPrescription(&exercise, prescription, trainingMaxes)
+		today.Exercises = append(today.Exercises, exercise)
+	}
+
+	return today
+}
+
+func applyPrescription(exercise *dto.PrescribedExerciseDto, prescription models.ProgramPrescription, trainingMaxes map[uint]decimal.Decimal) {
+	exercise.Sets = prescription.Sets
+	exercise.Reps = prescription.Reps
+	exercise.Amrap = prescription.Amrap
+
+	if prescription.PercentOfTrainingMax == nil {
+		return
+	}
+
+	exercise.PercentOfTrainingMax = prescription.PercentOfTrainingMax
+	if trainingMax, ok := trainingMaxes[prescription.ExerciseID]; ok {
+		weight := roundWeight(trainingMax.MulFloat(float64(*prescription.PercentOfTrainingMax)/100), programWeightIncrement)
+		exercise.TrainingMax = &trainingMax
+		exercise.Weight = &weight
+	}
+}
+
+// completeProgramDay checks the workout's sets against the program's next
+// day, updates training maxes per the progression rules and moves the
+// program cursor forward. Must run inside a transaction that locked the
+// program.
+func completeProgramDay(tx *gorm.DB, program *models.Program, workout *models.Workout) (*dto.ProgramSessionResultDto, error) {
+	today := prescribeNextDay(program)
+	if today == nil {
+		return nil, errors.New("Program has no days")
+	}
+
+	// Only the workout started for the expected day can complete it
+	if workout.ProgramID == nil || *workout.ProgramID != program.ID ||
+		workout.ProgramDayID == nil || *workout.ProgramDayID != today.ProgramDayID {
+		return nil, errProgramDayMismatch
+	}
+
+	// The program is locked, idx_program_session_workout backs this up
+	var existing int64
+	result := tx.Model(&models.ProgramSession{}).
+		Where("program_id = ? AND workout_id = ?", program.ID, workout.ID).
+		Count(&existing)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if existing > 0 {
+		return nil, errProgramDayCompleted
+	}
+
+	var records []models.Record
+	result = tx.Preload("Sets").
+		Where("workout_id = ? AND placeholder = ?", workout.ID, false).
+		Find(&records)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	setsByExercise := make(map[uint][]models.Set)
+	for _, record := range records {
+		setsByExercise[record.ExerciseID] = append(setsByExercise[record.ExerciseID], record.Sets...)
+	}
+
+	rules := make(map[uint]models.ProgramProgressRule, len(program.Rules))
+	for _, rule := range program.Rules {
+		rules[rule.ExerciseID] = rule
+	}
+
+	trainingMaxes := make(map[uint]*models.ProgramTrainingMax, len(program.TrainingMaxes))
+	for i := range program.TrainingMaxes {
+		trainingMaxes[program.TrainingMaxes[i].ExerciseID] = &program.TrainingMaxes[i]
+	}
+
+	sessionResult := &dto.ProgramSessionResultDto{
+		ProgramID: program.ID,
+		WorkoutID: workout.ID,
+		Succeeded: true,
+	}
+
+	for _, prescribed := range today.Exercises {
+		progression := dto.ExerciseProgressionDto{
+			ExerciseID:     prescribed.ExerciseID,
+			PrescribedSets: prescribed.Sets,
+		}
+
+		for _, set := range setsByExercise[prescribed.ExerciseID] {
+			if set.Reps < prescribed.Reps {
+				continue
+			}
+			if prescribed.Weight != nil && set.Weight.Float64() < prescribed.Weight.Float64()-prescribedWeightTolerance {
+				continue
+			}
+			progression.CompletedSets++
+		}
+		progression.Succeeded = progression.CompletedSets >= prescribed.Sets
+
+		if trainingMax, ok := trainingMaxes[prescribed.ExerciseID]; ok {
+			if rule, ok := rules[prescribed.ExerciseID]; ok {
+				previous := trainingMax.Weight
+				progression.PreviousMax = &previous
+				progression.Deloaded = applyProgressRule(trainingMax, rule, progression.Succeeded)
+
+				if err := tx.Save(trainingMax).Error; err != nil {
+					return nil, err
+				}
+			}
+
+			current := trainingMax.Weight
+			progression.TrainingMax = &current
+			progression.Failures = trainingMax.Failures
+		}
+
+		if !progression.Succeeded {
+			sessionResult.Succeeded = false
+		}
+		sessionResult.Exercises = append(sessionResult.Exercises, progression)
+	}
+
+	session := models.ProgramSession{
+		ProgramID:    program.ID,
+		ProgramDayID: today.ProgramDayID,
+		WorkoutID:    workout.ID,
+		Cycle:        program.Cycle,
+		Succeeded:    sessionResult.Succeeded,
+	}
+	if err := tx.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	// Advance to the next day, wrapping into a new cycle after the last one
+	program.NextDayIndex++
+	if program.NextDayIndex >= len(programDays(program)) {
+		program.NextDayIndex = 0
+		program.Cycle++
+	}
+
+	err := tx.Model(program).Updates(map[string]interface{}{
+		"next_day_index": program.NextDayIndex,
+		"cycle":          program.Cycle,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sessionResult.Next = prescribeNextDay(program)
+
+	return sessionResult, nil
+}
+
+// applyProgressRule updates a training max after a session and reports
+// whether it was deloaded
+func applyProgressRule(trainingMax *models.ProgramTrainingMax, rule models.ProgramProgressRule, succeeded bool) bool {
+	if succeeded {
+		trainingMax.Weight += rule.Increment
+		trainingMax.Failures = 0
+		return false
+	}
+
+	trainingMax.Failures++
+	if trainingMax.Failures < rule.FailureThreshold {
+		return false
+	}
+
+	trainingMax.Weight = roundWeight(trainingMax.Weight.MulFloat(1-float64(rule.DeloadPercent)/100), programWeightIncrement)
+	trainingMax.Failures = 0
+	return true
+}
+
+// roundWeight rounds a weight to the nearest multiple of increment
+func roundWeight(weight decimal.Decimal, increment decimal.Decimal) decimal.Decimal {
+	return increment.MulInt(int(math.Round(weight.Float64() / increment.Float64())))
+}
@@ -0,0 +1,508 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/database"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/internal/units"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProgramHandler struct {
+	db *database.DBInstance
+}
+
+func NewProgramHandler(db *database.DBInstance) *ProgramHandler {
+	return &ProgramHandler{db: db}
+}
+
+func (h *ProgramHandler) GetPrograms(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var programs []models.Program
+	result := h.db.DB.Where("user_id = ?", userID).Find(&programs)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"programs": programs,
+		"count":    len(programs),
+	})
+}
+
+func (h *ProgramHandler) GetProgram(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	programID, err := c.ParamsInt("id")
+	if err != nil || programID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid program ID",
+		})
+	}
+
+	program, err := findUserProgram(h.db.DB, uint(programID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Program not found or doesn't belong to you",
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	programToUnit(program, unit)
+
+	return c.JSON(program)
+}
+
+func (h *ProgramHandler) CreateProgram(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var programDto dto.ProgramDto
+	if err := c.BodyParser(&programDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(programDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	// Every day has to be built on one of the user's own templates
+	var templateIDs []uint
+	var exerciseIDs []uint
+	for _, week := range programDto.Weeks {
+		for _, day := range week.Days {
+			templateIDs = append(templateIDs, day.WorkoutTemplateID)
+			for _, prescription := range day.Prescriptions {
+				exerciseIDs = append(exerciseIDs, prescription.ExerciseID)
+			}
+		}
+	}
+	for _, trainingMax := range programDto.TrainingMaxes {
+		exerciseIDs = append(exerciseIDs, trainingMax.ExerciseID)
+	}
+	for _, rule := range programDto.Rules {
+		exerciseIDs = append(exerciseIDs, rule.ExerciseID)
+	}
+
+	var templateCount int64
+	h.db.DB.Model(&models.WorkoutTemplate{}).
+		Where("id IN ? AND user_id = ?", templateIDs, userID).
+		Distinct("id").
+		Count(&templateCount)
+	if int(templateCount) != len(uniqueIDs(templateIDs)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "One or more templates do not exist or don't belong to you",
+		})
+	}
+
+	if len(exerciseIDs) > 0 {
+		if err := checkExercisesExist(h.db.DB, exerciseIDs); err != nil {
+			return respondExerciseCheckError(c, err, "ExerciseID", exerciseIDs)
+		}
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	programDtoToKilograms(&programDto, unit)
+
+	program := programFromDto(programDto)
+	program.UserID = userID
+
+	if err := h.db.DB.Create(&program).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	completeProgram, _ := findUserProgram(h.db.DB, program.ID, userID)
+	if completeProgram != nil {
+		programToUnit(completeProgram, unit)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(completeProgram)
+}
+
+func (h *ProgramHandler) DeleteProgram(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	programID, err := c.ParamsInt("id")
+	if err != nil || programID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid program ID",
+		})
+	}
+
+	result := h.db.DB.Where("id = ? AND user_id = ?", programID, userID).Delete(&models.Program{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Program not found or doesn't belong to you",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetProgramToday returns the next prescribed session of a program
+func (h *ProgramHandler) GetProgramToday(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	programID, err := c.ParamsInt("id")
+	if err != nil || programID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid program ID",
+		})
+	}
+
+	program, err := findUserProgram(h.db.DB, uint(programID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Program not found or doesn't belong to you",
+		})
+	}
+
+	today := prescribeNextDay(program)
+	if today == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Program has no days",
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	prescriptionToUnit(today, unit)
+
+	return c.JSON(today)
+}
+
+// StartProgramToday creates a workout for the next prescribed session with
+// placeholder records holding the prescribed sets
+func (h *ProgramHandler) StartProgramToday(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	programID, err := c.ParamsInt("id")
+	if err != nil || programID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid program ID",
+		})
+	}
+
+	program, err := findUserProgram(h.db.DB, uint(programID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Program not found or doesn't belong to you",
+		})
+	}
+
+	today := prescribeNextDay(program)
+	if today == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Program has no days",
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Placeholder sets hold weights that can be loaded in the user's unit
+	prescriptionToUnit(today, unit)
+
+	date := time.Now()
+
+	workout := models.Workout{
+		UserID:       userID,
+		Label:        today.Label,
+		Date:         &date,
+		ProgramID:    &program.ID,
+		ProgramDayID: &today.ProgramDayID,
+	}
+
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&workout).Error; err != nil {
+			return err
+		}
+
+		for position, exercise := range today.Exercises {
+			sets := make([]models.Set, exercise.Sets)
+			for i := range sets {
+				sets[i].Reps = exercise.Reps
+				if exercise.Weight != nil {
+					sets[i].Weight = units.ToKilograms(*exercise.Weight, unit)
+				}
+			}
+
+			record := models.Record{
+				ExerciseID:  exercise.ExerciseID,
+				UserID:      userID,
+				Date:        workout.Date,
+				WorkoutID:   &workout.ID,
+				Position:    position,
+				Placeholder: true,
+				Sets:        sets,
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	detail, err := NewWorkoutHandler(h.db).loadWorkoutDetail(workout.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(detail)
+}
+
+// CompleteProgramDay evaluates a logged workout against the next prescribed
+// session, applies the progression rules and advances the program
+func (h *ProgramHandler) CompleteProgramDay(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	programID, err := c.ParamsInt("id")
+	if err != nil || programID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid program ID",
+		})
+	}
+
+	var completeDto dto.CompleteProgramDayDto
+	if err := c.BodyParser(&completeDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(completeDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	workout, err := findUserWorkout(h.db.DB, completeDto.WorkoutID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workout not found or doesn't belong to you",
+		})
+	}
+
+	var sessionResult *dto.ProgramSessionResultDto
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		program, err := lockUserProgram(tx, uint(programID), userID)
+		if err != nil {
+			return err
+		}
+
+		sessionResult, err = completeProgramDay(tx, program, workout)
+		return err
+	})
+	if errors.Is(err, errProgramNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, errProgramDayCompleted) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, errProgramDayMismatch) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	programResultToUnit(sessionResult, unit)
+
+	return c.JSON(sessionResult)
+}
+
+func programFromDto(programDto dto.ProgramDto) models.Program {
+	program := models.Program{
+		Name:        programDto.Name,
+		Description: programDto.Description,
+	}
+
+	for weekIndex, weekDto := range programDto.Weeks {
+		week := models.ProgramWeek{Number: weekIndex + 1}
+		for dayIndex, dayDto := range weekDto.Days {
+			day := models.ProgramDay{
+				Position:          dayIndex,
+				Label:             dayDto.Label,
+				WorkoutTemplateID: dayDto.WorkoutTemplateID,
+			}
+			for _, prescriptionDto := range dayDto.Prescriptions {
+				day.Prescriptions = append(day.Prescriptions, models.ProgramPrescription{
+					ExerciseID:           prescriptionDto.ExerciseID,
+					Sets:                 prescriptionDto.Sets,
+					Reps:                 prescriptionDto.Reps,
+					PercentOfTrainingMax: prescriptionDto.PercentOfTrainingMax,
+					Amrap:                prescriptionDto.Amrap,
+				})
+			}
+			week.Days = append(week.Days, day)
+		}
+		program.Weeks = append(program.Weeks, week)
+	}
+
+	for _, trainingMaxDto := range programDto.TrainingMaxes {
+		program.TrainingMaxes = append(program.TrainingMaxes, models.ProgramTrainingMax{
+			ExerciseID: trainingMaxDto.ExerciseID,
+			Weight:     trainingMaxDto.Weight,
+		})
+	}
+
+	for _, ruleDto := range programDto.Rules {
+		program.Rules = append(program.Rules, models.ProgramProgressRule{
+			ExerciseID:       ruleDto.ExerciseID,
+			Increment:        ruleDto.Increment,
+			FailureThreshold: ruleDto.FailureThreshold,
+			DeloadPercent:    ruleDto.DeloadPercent,
+		})
+	}
+
+	return program
+}
+
+// lockUserProgram locks a program for the rest of the transaction and loads
+// it. Completions wait on the lock, so each one sees the cursor and training
+// maxes the previous one left.
+func lockUserProgram(tx *gorm.DB, programID uint, userID uint) (*models.Program, error) {
+	var locked models.Program
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("id = ? AND user_id = ?", programID, userID).
+		First(&locked).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errProgramNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return findUserProgram(tx, locked.ID, userID)
+}
+
+func findUserProgram(db *gorm.DB, programID uint, userID uint) (*models.Program, error) {
+	var program models.Program
+	result := db.
+		Preload("Weeks", func(db *gorm.DB) *gorm.DB {
+			return db.Order("number ASC")
+		}).
+		Preload("Weeks.Days", orderByPosition).
+		Preload("Weeks.Days.Prescriptions").
+		Preload("Weeks.Days.Prescriptions.Exercise").
+		Preload("Weeks.Days.WorkoutTemplate").
+		Preload("Weeks.Days.WorkoutTemplate.Exercises", orderByPosition).
+		Preload("Weeks.Days.WorkoutTemplate.Exercises.Exercise").
+		Preload("TrainingMaxes").
+		Preload("Rules").
+		Where("id = ? AND user_id = ?", programID, userID).
+		First(&program)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &program, nil
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	var unique []uint
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+)
+
+// recordBatchError lists what is wrong with one record of a batch
+type recordBatchError struct {
+	Index   int                     `json:"index"`
+	Details []utils.ValidationError `json:"details"`
+}
+
+// CreateRecords creates many records with their sets in one transaction.
+// Every record is validated before anything is written, a single invalid
+// one rejects the whole batch.
+func (h *RecordHandler) CreateRecords(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var batchDto dto.RecordBatchDto
+	if err := c.BodyParser(&batchDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(batchDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// References are looked up once for the whole batch
+	var exerciseIDs, workoutIDs []uint
+	for _, recordDto := range batchDto.Records {
+		exerciseIDs = append(exerciseIDs, recordDto.ExerciseID)
+		if recordDto.WorkoutID != nil {
+			workoutIDs = append(workoutIDs, *recordDto.WorkoutID)
+		}
+	}
+
+	existingExercises, err := existingIDs(h.db.DB.Model(&models.Exercise{}), exerciseIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	existingWorkouts, err := existingIDs(h.db.DB.Model(&models.Workout{}).Where("user_id = ?", userID), workoutIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	now := time.Now()
+	dates := make([]*time.Time, len(batchDto.Records))
+	var batchErrors []recordBatchError
+	onlyMissingReferences := true
+	for i, recordDto := range batchDto.Records {
+		details := utils.ValidateStruct(recordDto)
+		if len(details) > 0 {
+			onlyMissingReferences = false
+		}
+
+		if recordDto.ExerciseID != 0 && !existingExercises[recordDto.ExerciseID] {
+			details = append(details, utils.ValidationError{
+				Field:   "ExerciseID",
+				Tag:     "exists",
+				Value:   recordDto.ExerciseID,
+				Message: "Exercise not found",
+			})
+		}
+
+		if recordDto.WorkoutID != nil && !existingWorkouts[*recordDto.WorkoutID] {
+			details = append(details, utils.ValidationError{
+				Field:   "WorkoutID",
+				Tag:     "exists",
+				Value:   *recordDto.WorkoutID,
+				Message: "Workout not found or doesn't belong to you",
+			})
+		}
+
+		if recordDto.Date != nil && *recordDto.Date != "" {
+			parsed, dateError := utils.ParseLogDate("Date", *recordDto.Date, loc, now)
+			if dateError != nil {
+				details = append(details, *dateError)
+				onlyMissingReferences = false
+			} else {
+				dates[i] = &parsed
+			}
+		}
+
+		if len(details) > 0 {
+			batchErrors = append(batchErrors, recordBatchError{Index: i, Details: details})
+		}
+	}
+
+	if len(batchErrors) > 0 {
+		status := fiber.StatusBadRequest
+		if onlyMissingReferences {
+			status = fiber.StatusUnprocessableEntity
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": batchErrors,
+		})
+	}
+
+	recordIDs := make([]uint, len(batchDto.Records))
+	prs := make(map[uint][]models.PersonalRecord, len(batchDto.Records))
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		for i, recordDto := range batchDto.Records {
+			setDtosToKilograms(recordDto.Sets, unit)
+
+			record := models.Record{
+				ExerciseID: recordDto.ExerciseID,
+				UserID:     userID,
+				Date:       dates[i],
+			}
+
+			// Records of one workout are appended in the order they were sent
+			if recordDto.WorkoutID != nil {
+				position, err := nextWorkoutPosition(tx, *recordDto.WorkoutID)
+				if err != nil {
+					return err
+				}
+				record.WorkoutID = recordDto.WorkoutID
+				record.Position = position
+			}
+
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+
+			sets := make([]models.Set, len(recordDto.Sets))
+			for j, setDto := range recordDto.Sets {
+				sets[j] = models.Set{
+					Reps:     setDto.Reps,
+					Weight:   setDto.Weight,
+					RecordID: record.ID,
+				}
+			}
+			if err := tx.Create(&sets).Error; err != nil {
+				return err
+			}
+
+			recordIDs[i] = record.ID
+			prs[record.ID] = []models.PersonalRecord{}
+		}
+
+		// The history of each exercise is rebuilt once for the whole batch,
+		// which compares its records against each other as well
+		if err := recomputeExercisePRs(tx, userID, uniqueIDs(exerciseIDs)); err != nil {
+			return err
+		}
+
+		var batchPRs []models.PersonalRecord
+		if err := tx.Where("record_id IN ?", recordIDs).Order("id ASC").Find(&batchPRs).Error; err != nil {
+			return err
+		}
+		for _, pr := range batchPRs {
+			prs[pr.RecordID] = append(prs[pr.RecordID], pr)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Load all created records with relationships at once
+	var records []models.Record
+	if err := h.db.DB.Preload("Exercise").Preload("Sets").Where("id IN ?", recordIDs).Find(&records).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	recordsToUnit(records, unit)
+
+	byID := make(map[uint]models.Record, len(records))
+	for _, record := range records {
+		byID[record.ID] = record
+	}
+
+	created := make([]dto.RecordWithPRsDto, len(recordIDs))
+	for i, recordID := range recordIDs {
+		personalRecordsToUnit(prs[recordID], unit)
+		created[i] = withPRFlags(byID[recordID], prs[recordID])
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"records": created,
+		"count":   len(created),
+	})
+}
+
+// existingIDs returns which of the given IDs exist in the model of query
+func existingIDs(query *gorm.DB, ids []uint) (map[uint]bool, error) {
+	existing := make(map[uint]bool, len(ids))
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	var found []uint
+	if err := query.Where("id IN ?", uniqueIDs(ids)).Pluck("id", &found).Error; err != nil {
+		return nil, err
+	}
+
+	for _, id := range found {
+		existing[id] = true
+	}
+	return existing, nil
+}
@@ -0,0 +1,501 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/internal/units"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	errWorkoutNotFound = errors.New("Workout not found or doesn't belong to you")
+	errLastSet         = errors.New("A record keeps at least one set")
+)
+
+// PatchRecord changes only the given fields of a record, its sets keep their
+// IDs and timestamps
+func (h *RecordHandler) PatchRecord(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	recordID, err := c.ParamsInt("id")
+	if err != nil || recordID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid record ID",
+		})
+	}
+
+	var patchDto dto.PatchRecordDto
+	if err := c.BodyParser(&patchDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(patchDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	if patchDto.ExerciseID != nil {
+		if ok, err := checkExerciseReference(c, h.db.DB, "ExerciseID", *patchDto.ExerciseID); !ok {
+			return err
+		}
+	}
+
+	var date *time.Time
+	if patchDto.Date.Value != nil && *patchDto.Date.Value != "" {
+		loc, err := userLocation(h.db.DB, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		parsed, dateError := utils.ParseLogDate("Date", *patchDto.Date.Value, loc, time.Now())
+		if dateError != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Validation failed",
+				"details": []utils.ValidationError{*dateError},
+			})
+		}
+		date = &parsed
+	}
+
+	record, err := findUserRecord(h.db.DB, uint(recordID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Record not found or doesn't belong to you",
+		})
+	}
+
+	if ok, err := checkIfMatch(c, record.Version); !ok {
+		return err
+	}
+
+	var prs []models.PersonalRecord
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := claimVersion(tx, &models.Record{}, record.ID, record.Version); err != nil {
+			return err
+		}
+		record.Version++
+
+		// Only the given columns are written, whatever else changed since the
+		// record was loaded stays
+		var columns []string
+		previousExerciseID := record.ExerciseID
+		if patchDto.ExerciseID != nil {
+			record.ExerciseID = *patchDto.ExerciseID
+			columns = append(columns, "exercise_id")
+		}
+		if patchDto.Date.Set {
+			record.Date = date
+			columns = append(columns, "date")
+		}
+
+		var previousWorkoutID *uint
+		if patchDto.WorkoutID.Set {
+			var err error
+			previousWorkoutID, err = moveRecordToWorkout(tx, record, patchDto.WorkoutID.Value, userID)
+			if err != nil {
+				return err
+			}
+			columns = append(columns, recordPlacementColumns...)
+		}
+
+		if len(columns) > 0 {
+			if err := tx.Model(record).Select(columns).Updates(record).Error; err != nil {
+				return err
+			}
+		}
+
+		// Drop the group the record left if it became too small
+		if previousWorkoutID != nil {
+			if err := pruneWorkoutGroups(tx, *previousWorkoutID); err != nil {
+				return err
+			}
+		}
+
+		// The date may have moved, PRs of every later record can change, and
+		// a record moved to another exercise leaves a gap in the old one
+		var err error
+		prs, err = refreshRecordPRs(tx, userID, record.ID, previousExerciseID, record.ExerciseID)
+		return err
+	})
+	if errors.Is(err, errWorkoutNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, errVersionConflict) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.respondWithRecord(c, fiber.StatusOK, record.ID, userID, prs)
+}
+
+// AddRecordSet appends a set to a record
+func (h *RecordHandler) AddRecordSet(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	recordID, err := c.ParamsInt("id")
+	if err != nil || recordID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid record ID",
+		})
+	}
+
+	var setDto dto.SetDto
+	if err := c.BodyParser(&setDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(setDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	record, err := findUserRecord(h.db.DB, uint(recordID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Record not found or doesn't belong to you",
+		})
+	}
+
+	if ok, err := checkIfMatch(c, record.Version); !ok {
+		return err
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var prs []models.PersonalRecord
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := claimVersion(tx, &models.Record{}, record.ID, record.Version); err != nil {
+			return err
+		}
+		record.Version++
+
+		set := models.Set{
+			Reps:     setDto.Reps,
+			Weight:   units.ToKilograms(setDto.Weight, unit),
+			RecordID: record.ID,
+		}
+		if err := tx.Create(&set).Error; err != nil {
+			return err
+		}
+
+		var err error
+		prs, err = afterSetChange(tx, record)
+		return err
+	})
+	if errors.Is(err, errVersionConflict) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.respondWithRecord(c, fiber.StatusCreated, record.ID, userID, prs)
+}
+
+// UpdateRecordSet corrects the reps or weight of a single set
+func (h *RecordHandler) UpdateRecordSet(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	recordID, err := c.ParamsInt("id")
+	if err != nil || recordID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid record ID",
+		})
+	}
+
+	setID, err := c.ParamsInt("setId")
+	if err != nil || setID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid set ID",
+		})
+	}
+
+	var patchDto dto.PatchSetDto
+	if err := c.BodyParser(&patchDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(patchDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	record, err := findUserRecord(h.db.DB, uint(recordID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Record not found or doesn't belong to you",
+		})
+	}
+
+	if ok, err := checkIfMatch(c, record.Version); !ok {
+		return err
+	}
+
+	var set models.Set
+	if err := h.db.DB.Where("id = ? AND record_id = ?", setID, record.ID).First(&set).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Set not found in this record",
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var prs []models.PersonalRecord
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := claimVersion(tx, &models.Record{}, record.ID, record.Version); err != nil {
+			return err
+		}
+		record.Version++
+
+		if patchDto.Reps != nil {
+			set.Reps = *patchDto.Reps
+		}
+		if patchDto.Weight != nil {
+			set.Weight = units.ToKilograms(*patchDto.Weight, unit)
+		}
+		if err := tx.Save(&set).Error; err != nil {
+			return err
+		}
+
+		var err error
+		prs, err = afterSetChange(tx, record)
+		return err
+	})
+	if errors.Is(err, errVersionConflict) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.respondWithRecord(c, fiber.StatusOK, record.ID, userID, prs)
+}
+
+// DeleteRecordSet removes a single set, the last one of a record stays
+func (h *RecordHandler) DeleteRecordSet(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	recordID, err := c.ParamsInt("id")
+	if err != nil || recordID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid record ID",
+		})
+	}
+
+	setID, err := c.ParamsInt("setId")
+	if err != nil || setID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid set ID",
+		})
+	}
+
+	record, err := findUserRecord(h.db.DB, uint(recordID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Record not found or doesn't belong to you",
+		})
+	}
+
+	if ok, err := checkIfMatch(c, record.Version); !ok {
+		return err
+	}
+
+	var set models.Set
+	if err := h.db.DB.Where("id = ? AND record_id = ?", setID, record.ID).First(&set).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Set not found in this record",
+		})
+	}
+
+	var prs []models.PersonalRecord
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := claimVersion(tx, &models.Record{}, record.ID, record.Version); err != nil {
+			return err
+		}
+		record.Version++
+
+		var count int64
+		if err := tx.Model(&models.Set{}).Where("record_id = ?", record.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count <= 1 {
+			return errLastSet
+		}
+
+		if err := tx.Delete(&set).Error; err != nil {
+			return err
+		}
+
+		var err error
+		prs, err = afterSetChange(tx, record)
+		return err
+	})
+	if errors.Is(err, errLastSet) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, errVersionConflict) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.respondWithRecord(c, fiber.StatusOK, record.ID, userID, prs)
+}
+
+// afterSetChange marks an edited placeholder as performed and detects the
+// PRs of the record again
+func afterSetChange(tx *gorm.DB, record *models.Record) ([]models.PersonalRecord, error) {
+	if record.Placeholder {
+		record.Placeholder = false
+		if err := tx.Model(record).Update("placeholder", false).Error; err != nil {
+			return nil, err
+		}
+	}
+	return storeRecordPRs(tx, record.ID)
+}
+
+// respondWithRecord loads a record with its exercise and sets in the user's
+// unit and flags the sets that set PRs
+func (h *RecordHandler) respondWithRecord(c *fiber.Ctx, status int, recordID uint, userID uint, prs []models.PersonalRecord) error {
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var record models.Record
+	if err := h.db.DB.Preload("Exercise").Preload("Sets").First(&record, recordID).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	recordToUnit(&record, unit)
+	personalRecordsToUnit(prs, unit)
+
+	setETag(c, record.Version)
+	return c.Status(status).JSON(withPRFlags(record, prs))
+}
+
+// recordPlacementColumns are the columns moveRecordToWorkout changes
+var recordPlacementColumns = []string{"workout_id", "workout_group_id", "position"}
+
+// moveRecordToWorkout attaches a record to the end of another workout, or
+// detaches it when workoutID is nil. It returns the workout the record left
+// a group of, whose groups may need pruning.
+func moveRecordToWorkout(tx *gorm.DB, record *models.Record, workoutID *uint, userID uint) (*uint, error) {
+	if workoutID != nil && record.WorkoutID != nil && *record.WorkoutID == *workoutID {
+		return nil, nil
+	}
+	if workoutID == nil && record.WorkoutID == nil {
+		return nil, nil
+	}
+
+	// Leaving a workout also leaves its group
+	var previousWorkoutID *uint
+	if record.WorkoutGroupID != nil {
+		previousWorkoutID = record.WorkoutID
+		record.WorkoutGroupID = nil
+	}
+
+	if workoutID == nil {
+		record.WorkoutID = nil
+		record.Position = 0
+		return previousWorkoutID, nil
+	}
+
+	if _, err := findUserWorkout(tx, *workoutID, userID); err != nil {
+		return nil, errWorkoutNotFound
+	}
+
+	position, err := nextWorkoutPosition(tx, *workoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	record.WorkoutID = workoutID
+	record.Position = position
+	return previousWorkoutID, nil
+}
+
+// findUserRecord loads a record only if it belongs to the given user
+func findUserRecord(db *gorm.DB, recordID uint, userID uint) (*models.Record, error) {
+	var record models.Record
+	if err := db.Where("id = ? AND user_id = ?", recordID, userID).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/dto"
+	"gorm.io/gorm"
+)
+
+// recordDayExpr is the point in time a record counts towards
+const recordDayExpr = "COALESCE(records.date, records.created_at)"
+
+// recordCursor points right after the last record of a page
+type recordCursor struct {
+	day time.Time
+	id  uint
+}
+
+func encodeRecordCursor(cursor recordCursor) string {
+	raw := fmt.Sprintf("%d:%d", cursor.day.UnixNano(), cursor.id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeRecordCursor(value string) (recordCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return recordCursor{}, errors.New("Invalid cursor")
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 {
+		return recordCursor{}, errors.New("Invalid cursor")
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return recordCursor{}, errors.New("Invalid cursor")
+	}
+
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return recordCursor{}, errors.New("Invalid cursor")
+	}
+
+	return recordCursor{day: time.Unix(0, nanos).UTC(), id: uint(id)}, nil
+}
+
+// applyRecordFilters narrows a records query by date range, exercise, muscle
+// and category. Days of the range are those of loc. Exercise based filters
+// join the exercises table.
+func applyRecordFilters(query *gorm.DB, filters dto.RecordsQueryDto, loc *time.Location) *gorm.DB {
+	if filters.From != "" {
+		from, _ := time.ParseInLocation("2006-01-02", filters.From, loc)
+		query = query.Where(recordDayExpr+" >= ?", from)
+	}
+
+	if filters.To != "" {
+		// The upper bound is inclusive of the whole day
+		to, _ := time.ParseInLocation("2006-01-02", filters.To, loc)
+		query = query.Where(recordDayExpr+" < ?", to.AddDate(0, 0, 1))
+	}
+
+	if len(filters.ExerciseIDs) > 0 {
+		query = query.Where("records.exercise_id IN ?", filters.ExerciseIDs)
+	}
+
+	if filters.Muscle != "" || filters.Category != "" {
+		query = query.Joins("JOIN exercises ON exercises.id = records.exercise_id")
+	}
+
+	// Muscles are stored as JSON arrays of strings in text columns, matched
+	// by containment so the muscle must equal one of the elements
+	if filters.Muscle != "" {
+		muscle, _ := json.Marshal([]string{strings.ToLower(filters.Muscle)})
+		if filters.IncludeSecondary {
+			query = query.Where("("+muscleContainsSQL("exercises.primary_muscles")+" OR "+muscleContainsSQL("exercises.secondary_muscles")+")", string(muscle), string(muscle))
+		} else {
+			query = query.Where(muscleContainsSQL("exercises.primary_muscles"), string(muscle))
+		}
+	}
+
+	if filters.Category != "" {
+		query = query.Where("LOWER(exercises.category) = ?", strings.ToLower(filters.Category))
+	}
+
+	return query
+}
+
+// muscleContainsSQL checks that a muscles column contains the JSON array
+// given as its argument, ignoring case. Empty columns contain nothing.
+func muscleContainsSQL(column string) string {
+	return "LOWER(NULLIF(" + column + ", ''))::jsonb @> ?::jsonb"
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -8,10 +9,16 @@ import (
 	"github.com/nagy135/fitness-tracker/database"
 	"github.com/nagy135/fitness-tracker/dto"
 	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/internal/strength"
 	"github.com/nagy135/fitness-tracker/models"
 	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
 )
 
+// defaultRecordsPageSize is used when no limit is given
+const defaultRecordsPageSize = 50
+
 type RecordHandler struct {
 	db *database.DBInstance
 }
@@ -28,8 +35,82 @@ func (h *RecordHandler) GetRecords(c *fiber.Ctx) error {
 		})
 	}
 
+	var recordsQuery dto.RecordsQueryDto
+	if err := c.QueryParser(&recordsQuery); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse query",
+		})
+	}
+
+	if errors := utils.ValidateStruct(recordsQuery); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	filtered := applyRecordFilters(h.db.DB.Model(&models.Record{}).Where("records.user_id = ?", userID), recordsQuery, loc)
+
+	var total int64
+	if err := filtered.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	ascending := recordsQuery.Sort == "date_asc"
+	direction := "DESC"
+	if ascending {
+		direction = "ASC"
+	}
+
+	query := filtered.Session(&gorm.Session{}).
+		Select("records.*").
+		Order(recordDayExpr + " " + direction + ", records.id " + direction)
+
+	if recordsQuery.Cursor != "" {
+		cursor, err := decodeRecordCursor(recordsQuery.Cursor)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		comparison := "<"
+		if ascending {
+			comparison = ">"
+		}
+		query = query.Where("("+recordDayExpr+", records.id) "+comparison+" (?, ?)", cursor.day, cursor.id)
+	}
+
+	// Records are always paged, the history grows for years
+	limit := recordsQuery.Limit
+	if limit == 0 {
+		limit = defaultRecordsPageSize
+	}
+	query = query.Limit(limit + 1)
+
 	var records []models.Record
-	result := h.db.DB.Preload("Exercise").Preload("Sets").Where("user_id = ?", userID).Find(&records)
+	result := query.
+		Preload("Exercise", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id, name, total_weight_multiplier, category, primary_muscles, secondary_muscles")
+		}).
+		Preload("Sets").
+		Find(&records)
 
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -37,12 +118,62 @@ func (h *RecordHandler) GetRecords(c *fiber.Ctx) error {
 		})
 	}
 
+	// The extra record fetched tells whether another page exists
+	var nextCursor *string
+	if len(records) > limit {
+		records = records[:limit]
+		last := records[len(records)-1]
+		day := last.CreatedAt
+		if last.Date != nil {
+			day = *last.Date
+		}
+		cursor := encodeRecordCursor(recordCursor{day: day, id: last.ID})
+		nextCursor = &cursor
+	}
+
+	recordsToUnit(records, unit)
+
 	return c.JSON(fiber.Map{
-		"records": records,
-		"count":   len(records),
+		"records":    records,
+		"count":      len(records),
+		"total":      total,
+		"nextCursor": nextCursor,
 	})
 }
 
+// GetRecord returns a single record, its ETag is what updates have to match
+func (h *RecordHandler) GetRecord(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	recordID, err := c.ParamsInt("id")
+	if err != nil || recordID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid record ID",
+		})
+	}
+
+	record, err := findUserRecord(h.db.DB, uint(recordID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Record not found or doesn't belong to you",
+		})
+	}
+
+	var prs []models.PersonalRecord
+	if err := h.db.DB.Where("record_id = ?", record.ID).Find(&prs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.respondWithRecord(c, fiber.StatusOK, record.ID, userID, prs)
+}
+
 func (h *RecordHandler) CreateRecord(c *fiber.Ctx) error {
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
@@ -65,6 +196,39 @@ func (h *RecordHandler) CreateRecord(c *fiber.Ctx) error {
 		})
 	}
 
+	if ok, err := checkExerciseReference(c, h.db.DB, "ExerciseID", recordDto.ExerciseID); !ok {
+		return err
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	setDtosToKilograms(recordDto.Sets, unit)
+
+	// Plain days start at midnight in the user's timezone
+	var date *time.Time
+	if recordDto.Date != nil && *recordDto.Date != "" {
+		loc, err := userLocation(h.db.DB, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		parsed, dateError := utils.ParseLogDate("Date", *recordDto.Date, loc, time.Now())
+		if dateError != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Validation failed",
+				"details": []utils.ValidationError{*dateError},
+			})
+		}
+		date = &parsed
+	}
+
 	// Start a transaction
 	tx := h.db.DB.Begin()
 
@@ -72,13 +236,28 @@ func (h *RecordHandler) CreateRecord(c *fiber.Ctx) error {
 	record := models.Record{
 		ExerciseID: recordDto.ExerciseID,
 		UserID:     userID,
+		Date:       date,
 	}
 
-	// Set custom date if provided
-	if recordDto.Date != nil && *recordDto.Date != "" {
-		if parsedDate, err := time.Parse("2006-01-02", *recordDto.Date); err == nil {
-			record.Date = &parsedDate
+	// Attach to a workout if provided, appending it after the existing records
+	if recordDto.WorkoutID != nil {
+		if _, err := findUserWorkout(tx, *recordDto.WorkoutID, userID); err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Workout not found or doesn't belong to you",
+			})
+		}
+
+		position, err := nextWorkoutPosition(tx, *recordDto.WorkoutID)
+		if err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
 		}
+
+		record.WorkoutID = recordDto.WorkoutID
+		record.Position = position
 	}
 
 	if err := tx.Create(&record).Error; err != nil {
@@ -106,14 +285,26 @@ func (h *RecordHandler) CreateRecord(c *fiber.Ctx) error {
 		})
 	}
 
+	// Detect the PRs this record sets
+	prs, err := storeRecordPRs(tx, record.ID)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	// Commit the transaction
 	tx.Commit()
 
 	// Load the complete record with relationships
 	var completeRecord models.Record
 	h.db.DB.Preload("Exercise").Preload("Sets").First(&completeRecord, record.ID)
+	recordToUnit(&completeRecord, unit)
+	personalRecordsToUnit(prs, unit)
 
-	return c.Status(fiber.StatusCreated).JSON(completeRecord)
+	setETag(c, completeRecord.Version)
+	return c.Status(fiber.StatusCreated).JSON(withPRFlags(completeRecord, prs))
 }
 
 func (h *RecordHandler) UpdateRecord(c *fiber.Ctx) error {
@@ -146,6 +337,39 @@ func (h *RecordHandler) UpdateRecord(c *fiber.Ctx) error {
 		})
 	}
 
+	if ok, err := checkExerciseReference(c, h.db.DB, "ExerciseID", updateRecordDto.ExerciseID); !ok {
+		return err
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	setDtosToKilograms(updateRecordDto.Sets, unit)
+
+	// An absent date keeps the current one, null or an empty string clears it
+	var date *time.Time
+	if updateRecordDto.Date.Value != nil && *updateRecordDto.Date.Value != "" {
+		loc, err := userLocation(h.db.DB, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		parsed, dateError := utils.ParseLogDate("Date", *updateRecordDto.Date.Value, loc, time.Now())
+		if dateError != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Validation failed",
+				"details": []utils.ValidationError{*dateError},
+			})
+		}
+		date = &parsed
+	}
+
 	// Check if record exists and belongs to the user
 	var existingRecord models.Record
 	result := h.db.DB.Where("id = ? AND user_id = ?", recordID, userID).First(&existingRecord)
@@ -155,9 +379,55 @@ func (h *RecordHandler) UpdateRecord(c *fiber.Ctx) error {
 		})
 	}
 
+	if ok, err := checkIfMatch(c, existingRecord.Version); !ok {
+		return err
+	}
+
 	// Start a transaction
 	tx := h.db.DB.Begin()
 
+	// Another request may have updated the record since it was loaded
+	if err := claimVersion(tx, &models.Record{}, existingRecord.ID, existingRecord.Version); err != nil {
+		tx.Rollback()
+		if errors.Is(err, errVersionConflict) {
+			return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update record",
+		})
+	}
+	existingRecord.Version++
+
+	// Move to another workout if requested, an absent workoutId keeps the current one
+	var previousWorkoutID *uint
+	if updateRecordDto.WorkoutID != nil && (existingRecord.WorkoutID == nil || *existingRecord.WorkoutID != *updateRecordDto.WorkoutID) {
+		if _, err := findUserWorkout(tx, *updateRecordDto.WorkoutID, userID); err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Workout not found or doesn't belong to you",
+			})
+		}
+
+		position, err := nextWorkoutPosition(tx, *updateRecordDto.WorkoutID)
+		if err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update record",
+			})
+		}
+
+		// Leaving a workout also leaves its group
+		if existingRecord.WorkoutGroupID != nil {
+			previousWorkoutID = existingRecord.WorkoutID
+			existingRecord.WorkoutGroupID = nil
+		}
+
+		existingRecord.WorkoutID = updateRecordDto.WorkoutID
+		existingRecord.Position = position
+	}
+
 	// Delete existing sets
 	if err := tx.Where("record_id = ?", recordID).Delete(&models.Set{}).Error; err != nil {
 		tx.Rollback()
@@ -166,16 +436,13 @@ func (h *RecordHandler) UpdateRecord(c *fiber.Ctx) error {
 		})
 	}
 
-	// Update the record
+	// Update the record, an edited placeholder counts as performed
+	previousExerciseID := existingRecord.ExerciseID
 	existingRecord.ExerciseID = updateRecordDto.ExerciseID
+	existingRecord.Placeholder = false
 
-	// Set custom date if provided
-	if updateRecordDto.Date != nil && *updateRecordDto.Date != "" {
-		if parsedDate, err := time.Parse("2006-01-02", *updateRecordDto.Date); err == nil {
-			existingRecord.Date = &parsedDate
-		}
-	} else {
-		existingRecord.Date = nil
+	if updateRecordDto.Date.Set {
+		existingRecord.Date = date
 	}
 
 	if err := tx.Save(&existingRecord).Error; err != nil {
@@ -203,14 +470,37 @@ func (h *RecordHandler) UpdateRecord(c *fiber.Ctx) error {
 		})
 	}
 
+	// Drop the group the record left if it became too small
+	if previousWorkoutID != nil {
+		if err := pruneWorkoutGroups(tx, *previousWorkoutID); err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update record",
+			})
+		}
+	}
+
+	// The record may have moved in time or to another exercise, so both
+	// exercises get their PR history rebuilt
+	prs, err := refreshRecordPRs(tx, userID, existingRecord.ID, previousExerciseID, existingRecord.ExerciseID)
+	if err != nil {
+		tx.Rollback()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update personal records",
+		})
+	}
+
 	// Commit the transaction
 	tx.Commit()
 
 	// Load the complete record with relationships
 	var completeRecord models.Record
 	h.db.DB.Preload("Exercise").Preload("Sets").First(&completeRecord, existingRecord.ID)
+	recordToUnit(&completeRecord, unit)
+	personalRecordsToUnit(prs, unit)
 
-	return c.JSON(completeRecord)
+	setETag(c, completeRecord.Version)
+	return c.JSON(withPRFlags(completeRecord, prs))
 }
 
 func (h *RecordHandler) GetExercisePR(c *fiber.Ctx) error {
@@ -237,13 +527,27 @@ func (h *RecordHandler) GetExercisePR(c *fiber.Ctx) error {
 		})
 	}
 
+	formula, err := strength.ParseFormula(c.Query("formula"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	type PRResponse struct {
-		MaxTotalWeight float32 `json:"maxTotalWeight"`
+		MaxTotalWeight decimal.Decimal `json:"maxTotalWeight"`
 		Date           string  `json:"date"`
 		RecordID       uint    `json:"recordId"`
 		Sets           []struct {
-			Reps   int     `json:"reps"`
-			Weight float32 `json:"weight"`
+			Reps   int             `json:"reps"`
+			Weight decimal.Decimal `json:"weight"`
 		} `json:"sets"`
 	}
 
@@ -257,49 +561,53 @@ func (h *RecordHandler) GetExercisePR(c *fiber.Ctx) error {
 
 	// Get all records for this exercise and user
 	var records []models.Record
-	result := h.db.DB.Preload("Sets").Where("user_id = ? AND exercise_id = ?", userID, exerciseID).Find(&records)
+	result := h.db.DB.Preload("Sets").Where("user_id = ? AND exercise_id = ? AND placeholder = ?", userID, exerciseID, false).Find(&records)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": result.Error.Error(),
 		})
 	}
 
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Everything below is computed in the user's unit, days are those of
+	// their timezone
+	recordsToUnit(records, unit)
+
 	if len(records) == 0 {
 		return c.JSON(fiber.Map{
-			"pr": nil,
+			"pr":  nil,
+			"prs": nil,
 		})
 	}
 
 	// Group records by date and calculate total weight per day
 	dailyTotals := make(map[string]struct {
-		totalWeight float32
+		totalWeight decimal.Decimal
 		recordID    uint
 	})
 
 	for _, record := range records {
-		// Use record date if available, otherwise use created_at
-		var recordDate time.Time
-		if record.Date != nil {
-			recordDate = *record.Date
-		} else {
-			recordDate = record.CreatedAt
-		}
-
-		dateKey := recordDate.Format("2006-01-02")
+		dateKey := strength.RecordDay(record, loc)
 
 		// Calculate total weight for this record
-		var recordTotalWeight float32
+		var recordTotalWeight decimal.Decimal
 		for _, set := range record.Sets {
-			recordTotalWeight += set.Weight * float32(set.Reps)
+			recordTotalWeight += set.Weight.MulInt(set.Reps)
 		}
 
 		// Apply exercise weight multiplier
-		recordTotalWeight *= exercise.TotalWeightMultiplier
+		recordTotalWeight = recordTotalWeight.MulFloat(float64(exercise.TotalWeightMultiplier))
 
 		// Add to daily total
 		if existing, exists := dailyTotals[dateKey]; exists {
 			dailyTotals[dateKey] = struct {
-				totalWeight float32
+				totalWeight decimal.Decimal
 				recordID    uint
 			}{
 				totalWeight: existing.totalWeight + recordTotalWeight,
@@ -307,7 +615,7 @@ func (h *RecordHandler) GetExercisePR(c *fiber.Ctx) error {
 			}
 		} else {
 			dailyTotals[dateKey] = struct {
-				totalWeight float32
+				totalWeight decimal.Decimal
 				recordID    uint
 			}{
 				totalWeight: recordTotalWeight,
@@ -323,14 +631,7 @@ func (h *RecordHandler) GetExercisePR(c *fiber.Ctx) error {
 		if maxPR == nil || data.totalWeight > maxPR.MaxTotalWeight {
 			// Find the record that contributed to this total
 			for _, record := range records {
-				var recordDate time.Time
-				if record.Date != nil {
-					recordDate = *record.Date
-				} else {
-					recordDate = record.CreatedAt
-				}
-				
-				if recordDate.Format("2006-01-02") == date {
+				if strength.RecordDay(record, loc) == date {
 					maxRecord = &record
 					break
 				}
@@ -347,14 +648,14 @@ func (h *RecordHandler) GetExercisePR(c *fiber.Ctx) error {
 	// Add sets data to the PR response
 	if maxPR != nil && maxRecord != nil {
 		maxPR.Sets = make([]struct {
-			Reps   int     `json:"reps"`
-			Weight float32 `json:"weight"`
+			Reps   int             `json:"reps"`
+			Weight decimal.Decimal `json:"weight"`
 		}, len(maxRecord.Sets))
 		
 		for i, set := range maxRecord.Sets {
 			maxPR.Sets[i] = struct {
-				Reps   int     `json:"reps"`
-				Weight float32 `json:"weight"`
+				Reps   int             `json:"reps"`
+				Weight decimal.Decimal `json:"weight"`
 			}{
 				Reps:   set.Reps,
 				Weight: set.Weight,
@@ -362,7 +663,11 @@ func (h *RecordHandler) GetExercisePR(c *fiber.Ctx) error {
 		}
 	}
 
+	// Records across every PR dimension, "pr" stays the best session by total weight
+	prs := strength.ComputeExercisePRs(records, exercise, formula, loc)
+
 	return c.JSON(fiber.Map{
-		"pr": maxPR,
+		"pr":  maxPR,
+		"prs": prs,
 	})
 }
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"github.com/nagy135/fitness-tracker/database"
+)
+
+// StatsHandler serves the analytics endpoints under /stats
+type StatsHandler struct {
+	db *database.DBInstance
+}
+
+func NewStatsHandler(db *database.DBInstance) *StatsHandler {
+	return &StatsHandler{db: db}
+}
@@ -0,0 +1,520 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/units"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	errClientIDRequired = errors.New("clientId is required to create")
+	errTargetRequired   = errors.New("id or clientId is required")
+	errVersionRequired  = errors.New("version is required to change a workout or record")
+	errRecordNotFound   = errors.New("Record not found or doesn't belong to you")
+	errSetNotFound      = errors.New("Set not found or doesn't belong to you")
+	errProgramWorkout   = errors.New("Workout completed a program day and can't be deleted")
+)
+
+// syncMutator applies the mutations of one push on behalf of a user
+type syncMutator struct {
+	userID uint
+	unit   units.Unit
+	loc    *time.Location
+	now    time.Time
+}
+
+// apply runs a mutation in its own transaction and reports how it went
+func (m syncMutator) apply(db *gorm.DB, index int, mutation dto.SyncMutationDto) dto.SyncResultDto {
+	result := dto.SyncResultDto{
+		Index:    index,
+		Entity:   mutation.Entity,
+		Op:       mutation.Op,
+		ClientID: mutation.ClientID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		switch mutation.Entity {
+		case "workout":
+			return m.applyWorkout(tx, mutation, &result)
+		case "record":
+			return m.applyRecord(tx, mutation, &result)
+		default:
+			return m.applySet(tx, mutation, &result)
+		}
+	})
+
+	switch {
+	case err == nil:
+		result.Status = dto.SyncApplied
+	case errors.Is(err, errVersionConflict):
+		result.Status = dto.SyncConflict
+		result.Error = err.Error()
+	default:
+		result.Status = dto.SyncRejected
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (m syncMutator) applyWorkout(tx *gorm.DB, mutation dto.SyncMutationDto, result *dto.SyncResultDto) error {
+	var data dto.SyncWorkoutDataDto
+	if err := decodeMutationData(mutation, &data); err != nil {
+		return err
+	}
+
+	if mutation.Op == "create" {
+		if mutation.ClientID == "" {
+			return errClientIDRequired
+		}
+
+		// A retried create finds the workout made the first time, even when
+		// it was deleted since
+		var existing models.Workout
+		if err := tx.Unscoped().Where("user_id = ? AND client_id = ?", m.userID, mutation.ClientID).First(&existing).Error; err == nil {
+			result.ID, result.Version = existing.ID, liveVersion(existing.Version, existing.DeletedAt)
+			return nil
+		}
+
+		if data.Label == nil {
+			return errors.New("label is required")
+		}
+
+		date, err := m.parseDate(data.Date)
+		if err != nil {
+			return err
+		}
+
+		workout := models.Workout{
+			UserID:   m.userID,
+			Label:    *data.Label,
+			Date:     date,
+			ClientID: &mutation.ClientID,
+		}
+		if err := tx.Create(&workout).Error; err != nil {
+			return err
+		}
+		result.ID, result.Version = workout.ID, workout.Version
+		return nil
+	}
+
+	workout, err := m.findWorkout(tx, mutation.ID, mutation.ClientID)
+	if err != nil {
+		return err
+	}
+	result.ID, result.Version = workout.ID, workout.Version
+
+	if err := claimMutationVersion(tx, &models.Workout{}, workout.ID, mutation.Version); err != nil {
+		return err
+	}
+	workout.Version++
+
+	if mutation.Op == "delete" {
+		var sessions int64
+		if err := tx.Model(&models.ProgramSession{}).Where("workout_id = ?", workout.ID).Count(&sessions).Error; err != nil {
+			return err
+		}
+		if sessions > 0 {
+			return errProgramWorkout
+		}
+
+		// Records outlive their workout, they are only detached
+		err := tx.Model(&models.Record{}).Where("workout_id = ?", workout.ID).Updates(map[string]interface{}{
+			"workout_id":       nil,
+			"workout_group_id": nil,
+			"position":         0,
+			"version":          bumpVersion,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("workout_id = ?", workout.ID).Delete(&models.WorkoutGroup{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(workout).Error; err != nil {
+			return err
+		}
+		result.Version = 0
+		return nil
+	}
+
+	var columns []string
+	if data.Label != nil {
+		workout.Label = *data.Label
+		columns = append(columns, "label")
+	}
+	if data.Date.Set {
+		date, err := m.parseDate(data.Date)
+		if err != nil {
+			return err
+		}
+		workout.Date = date
+		columns = append(columns, "date")
+	}
+
+	if len(columns) > 0 {
+		if err := tx.Model(workout).Select(columns).Updates(workout).Error; err != nil {
+			return err
+		}
+	}
+	result.Version = workout.Version
+	return nil
+}
+
+func (m syncMutator) applyRecord(tx *gorm.DB, mutation dto.SyncMutationDto, result *dto.SyncResultDto) error {
+	var data dto.SyncRecordDataDto
+	if err := decodeMutationData(mutation, &data); err != nil {
+		return err
+	}
+
+	if data.ExerciseID != nil {
+		if err := checkExercisesExist(tx, []uint{*data.ExerciseID}); err != nil {
+			return err
+		}
+	}
+
+	// The workout to move the record to, nil detaches it
+	var workoutID *uint
+	if data.WorkoutClientID != nil {
+		workout, err := m.findWorkout(tx, nil, *data.WorkoutClientID)
+		if err != nil {
+			return err
+		}
+		workoutID = &workout.ID
+	} else if data.WorkoutID.Value != nil {
+		workoutID = data.WorkoutID.Value
+	}
+	moveWorkout := data.WorkoutClientID != nil || data.WorkoutID.Set
+
+	if mutation.Op == "create" {
+		if mutation.ClientID == "" {
+			return errClientIDRequired
+		}
+
+		var existing models.Record
+		if err := tx.Unscoped().Where("user_id = ? AND client_id = ?", m.userID, mutation.ClientID).First(&existing).Error; err == nil {
+			result.ID, result.Version = existing.ID, liveVersion(existing.Version, existing.DeletedAt)
+			return nil
+		}
+
+		if data.ExerciseID == nil {
+			return errors.New("exerciseId is required")
+		}
+
+		date, err := m.parseDate(data.Date)
+		if err != nil {
+			return err
+		}
+
+		// Sets follow as their own mutations
+		record := models.Record{
+			ExerciseID: *data.ExerciseID,
+			UserID:     m.userID,
+			Date:       date,
+			ClientID:   &mutation.ClientID,
+		}
+		if _, err := moveRecordToWorkout(tx, &record, workoutID, m.userID); err != nil {
+			return err
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		result.ID, result.Version = record.ID, record.Version
+		return nil
+	}
+
+	record, err := m.findRecord(tx, mutation.ID, mutation.ClientID)
+	if err != nil {
+		return err
+	}
+	result.ID, result.Version = record.ID, record.Version
+
+	if err := claimMutationVersion(tx, &models.Record{}, record.ID, mutation.Version); err != nil {
+		return err
+	}
+	record.Version++
+
+	if mutation.Op == "delete" {
+		if err := tx.Where("record_id = ?", record.ID).Delete(&models.Set{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("record_id = ?", record.ID).Delete(&models.PersonalRecord{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(record).Error; err != nil {
+			return err
+		}
+		if record.WorkoutID != nil && record.WorkoutGroupID != nil {
+			if err := pruneWorkoutGroups(tx, *record.WorkoutID); err != nil {
+				return err
+			}
+		}
+		// Later records may have beaten the deleted one
+		if err := recomputeExercisePRs(tx, m.userID, []uint{record.ExerciseID}); err != nil {
+			return err
+		}
+		result.Version = 0
+		return nil
+	}
+
+	var columns []string
+	previousExerciseID := record.ExerciseID
+	if data.ExerciseID != nil {
+		record.ExerciseID = *data.ExerciseID
+		columns = append(columns, "exercise_id")
+	}
+	if data.Date.Set {
+		date, err := m.parseDate(data.Date)
+		if err != nil {
+			return err
+		}
+		record.Date = date
+		columns = append(columns, "date")
+	}
+
+	var previousWorkoutID *uint
+	if moveWorkout {
+		previousWorkoutID, err = moveRecordToWorkout(tx, record, workoutID, m.userID)
+		if err != nil {
+			return err
+		}
+		columns = append(columns, recordPlacementColumns...)
+	}
+
+	if len(columns) > 0 {
+		if err := tx.Model(record).Select(columns).Updates(record).Error; err != nil {
+			return err
+		}
+	}
+
+	if previousWorkoutID != nil {
+		if err := pruneWorkoutGroups(tx, *previousWorkoutID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := refreshRecordPRs(tx, m.userID, record.ID, previousExerciseID, record.ExerciseID); err != nil {
+		return err
+	}
+	result.Version = record.Version
+	return nil
+}
+
+// applySet changes a set of a record. Sets carry no version of their own,
+// the last change wins but still moves their record to a new version.
+func (m syncMutator) applySet(tx *gorm.DB, mutation dto.SyncMutationDto, result *dto.SyncResultDto) error {
+	var data dto.SyncSetDataDto
+	if err := decodeMutationData(mutation, &data); err != nil {
+		return err
+	}
+
+	var set models.Set
+	var record *models.Record
+
+	if mutation.Op == "create" {
+		if mutation.ClientID == "" {
+			return errClientIDRequired
+		}
+
+		var clientID string
+		if data.RecordClientID != nil {
+			clientID = *data.RecordClientID
+		}
+		var err error
+		record, err = m.findRecord(tx, data.RecordID, clientID)
+		if err != nil {
+			return err
+		}
+
+		// Client IDs of sets are unique within their record
+		var existing models.Set
+		if err := tx.Unscoped().Where("record_id = ? AND client_id = ?", record.ID, mutation.ClientID).First(&existing).Error; err == nil {
+			result.ID = existing.ID
+			return nil
+		}
+
+		if data.Reps == nil || data.Weight == nil {
+			return errors.New("reps and weight are required")
+		}
+
+		set = models.Set{
+			Reps:     *data.Reps,
+			Weight:   units.ToKilograms(*data.Weight, m.unit),
+			RecordID: record.ID,
+			ClientID: &mutation.ClientID,
+		}
+		if data.PerformedAt != nil {
+			performedAt, dateError := utils.ParseDateTime("PerformedAt", *data.PerformedAt, m.loc)
+			if dateError != nil {
+				return errors.New(dateError.Message)
+			}
+			set.PerformedAt = &performedAt
+		}
+		if err := tx.Create(&set).Error; err != nil {
+			return err
+		}
+	} else {
+		existing, err := m.findSet(tx, mutation.ID, mutation.ClientID)
+		if err != nil {
+			return err
+		}
+		set = *existing
+
+		record, err = findUserRecord(tx, set.RecordID, m.userID)
+		if err != nil {
+			return errRecordNotFound
+		}
+
+		if mutation.Op == "delete" {
+			var count int64
+			if err := tx.Model(&models.Set{}).Where("record_id = ?", record.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count <= 1 {
+				return errLastSet
+			}
+			if err := tx.Delete(&set).Error; err != nil {
+				return err
+			}
+		} else {
+			if data.Reps != nil {
+				set.Reps = *data.Reps
+			}
+			if data.Weight != nil {
+				set.Weight = units.ToKilograms(*data.Weight, m.unit)
+			}
+			if err := tx.Save(&set).Error; err != nil {
+				return err
+			}
+		}
+	}
+	result.ID = set.ID
+
+	if err := tx.Model(record).Update("version", bumpVersion).Error; err != nil {
+		return err
+	}
+	_, err := afterSetChange(tx, record)
+	return err
+}
+
+// findWorkout resolves a workout of the user by its server or client ID
+func (m syncMutator) findWorkout(tx *gorm.DB, id *uint, clientID string) (*models.Workout, error) {
+	query, err := byMutationTarget(tx.Where("user_id = ?", m.userID), "workouts", id, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	var workout models.Workout
+	if err := query.First(&workout).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errWorkoutNotFound
+		}
+		return nil, err
+	}
+	return &workout, nil
+}
+
+// findRecord resolves a record of the user by its server or client ID
+func (m syncMutator) findRecord(tx *gorm.DB, id *uint, clientID string) (*models.Record, error) {
+	query, err := byMutationTarget(tx.Where("user_id = ?", m.userID), "records", id, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	var record models.Record
+	if err := query.First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errRecordNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// findSet resolves a set of one of the user's records by its server or client ID
+func (m syncMutator) findSet(tx *gorm.DB, id *uint, clientID string) (*models.Set, error) {
+	query := tx.
+		Joins("JOIN records r ON r.id = sets.record_id AND r.deleted_at IS NULL").
+		Where("r.user_id = ?", m.userID)
+	query, err := byMutationTarget(query, "sets", id, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	var set models.Set
+	if err := query.First(&set).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errSetNotFound
+		}
+		return nil, err
+	}
+	return &set, nil
+}
+
+// liveVersion is the version a create retry reports, 0 when the row was
+// deleted since like a delete reports it
+func liveVersion(version uint, deletedAt gorm.DeletedAt) uint {
+	if deletedAt.Valid {
+		return 0
+	}
+	return version
+}
+
+// parseDate reads the date of a workout or record, null or empty clears it
+func (m syncMutator) parseDate(value dto.Optional[string]) (*time.Time, error) {
+	if value.Value == nil || *value.Value == "" {
+		return nil, nil
+	}
+
+	parsed, dateError := utils.ParseLogDate("Date", *value.Value, m.loc, m.now)
+	if dateError != nil {
+		return nil, errors.New(dateError.Message)
+	}
+	return &parsed, nil
+}
+
+// byMutationTarget narrows a query to the row a mutation addresses, the
+// server ID wins when both are given
+func byMutationTarget(query *gorm.DB, table string, id *uint, clientID string) (*gorm.DB, error) {
+	switch {
+	case id != nil:
+		return query.Where(table+".id = ?", *id), nil
+	case clientID != "":
+		return query.Where(table+".client_id = ?", clientID), nil
+	default:
+		return nil, errTargetRequired
+	}
+}
+
+// claimMutationVersion moves a workout or record on from the version the
+// client changed, which every update and delete has to name
+func claimMutationVersion(tx *gorm.DB, model any, id uint, version *uint) error {
+	if version == nil {
+		return errVersionRequired
+	}
+	return claimVersion(tx, model, id, *version)
+}
+
+// decodeMutationData reads and validates the data of a mutation
+func decodeMutationData(mutation dto.SyncMutationDto, data any) error {
+	if len(mutation.Data) > 0 {
+		if err := json.Unmarshal(mutation.Data, data); err != nil {
+			return errors.New("Cannot parse data")
+		}
+	}
+
+	if validationErrors := utils.ValidateStruct(data); len(validationErrors) > 0 {
+		messages := make([]string, len(validationErrors))
+		for i, validationError := range validationErrors {
+			messages[i] = validationError.Field + ": " + validationError.Message
+		}
+		return errors.New(strings.Join(messages, ", "))
+	}
+	return nil
+}
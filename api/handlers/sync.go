@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/database"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/internal/config"
+	"github.com/nagy135/fitness-tracker/internal/units"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+)
+
+type SyncHandler struct {
+	db  *database.DBInstance
+	cfg *config.Config
+}
+
+func NewSyncHandler(db *database.DBInstance, cfg *config.Config) *SyncHandler {
+	return &SyncHandler{db: db, cfg: cfg}
+}
+
+// syncCursorPrefix marks cursors holding a transaction ID, cursors of
+// earlier servers held a timestamp
+const syncCursorPrefix = "xid:"
+
+var (
+	errInvalidCursor = errors.New("Invalid cursor")
+	errStaleCursor   = errors.New("Cursor is from an older server version, pull again without since")
+)
+
+// syncWindow is the range of changes one pull returns. Every write to a
+// synced table stamps the row with the ID of its transaction in change_xid,
+// a trigger added by the data migrations does that. A pull returns rows stamped from Since up to Until,
+// the oldest transaction still running when the pull started. Everything
+// before it has committed, so rows of a transaction that is still open show
+// up in a later pull instead of falling behind the cursor. A zero Since is a
+// full sync.
+type syncWindow struct {
+	Since int64
+	Until int64
+}
+
+// changed limits a query to rows of table that changed within the window.
+// A full sync leaves deleted rows out.
+func (w syncWindow) changed(db *gorm.DB, table string) *gorm.DB {
+	if w.Since == 0 {
+		return db.Where(table+".deleted_at IS NULL AND "+table+".change_xid < ?", w.Until)
+	}
+	return db.Where(table+".change_xid >= ? AND "+table+".change_xid < ?", w.Since, w.Until)
+}
+
+// oldestRunningXID returns the ID of the oldest transaction still running,
+// every transaction before it has finished
+func oldestRunningXID(db *gorm.DB) (int64, error) {
+	var xid int64
+	err := db.Raw("SELECT txid_snapshot_xmin(txid_current_snapshot())").Scan(&xid).Error
+	return xid, err
+}
+
+func encodeSyncCursor(until int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(syncCursorPrefix + strconv.FormatInt(until, 10)))
+}
+
+func decodeSyncCursor(value string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+
+	xid, ok := strings.CutPrefix(string(raw), syncCursorPrefix)
+	if !ok {
+		if _, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+			return 0, errStaleCursor
+		}
+		return 0, errInvalidCursor
+	}
+
+	until, err := strconv.ParseInt(xid, 10, 64)
+	if err != nil || until <= 0 {
+		return 0, errInvalidCursor
+	}
+	return until, nil
+}
+
+// GetChanges returns the workouts, records, sets and exercises created,
+// updated or deleted since the cursor, weights in the user's unit
+func (h *SyncHandler) GetChanges(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var syncQuery dto.SyncQueryDto
+	if err := c.QueryParser(&syncQuery); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse query",
+		})
+	}
+
+	until, err := oldestRunningXID(h.db.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	window := syncWindow{Until: until}
+	if syncQuery.Since != "" {
+		since, err := decodeSyncCursor(syncQuery.Since)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		window.Since = since
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var workouts []models.Workout
+	result := window.changed(h.db.DB.Unscoped(), "workouts").
+		Where("workouts.user_id = ?", userID).
+		Order("workouts.id").
+		Find(&workouts)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	var records []models.Record
+	result = window.changed(h.db.DB.Unscoped(), "records").
+		Where("records.user_id = ?", userID).
+		Order("records.id").
+		Find(&records)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	var sets []models.Set
+	result = window.changed(h.db.DB.Unscoped(), "sets").
+		Joins("JOIN records r ON r.id = sets.record_id").
+		Where("r.user_id = ?", userID).
+		Order("sets.id").
+		Find(&sets)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	// Exercises are a catalog shared by all users
+	var exercises []models.Exercise
+	result = window.changed(h.db.DB.Unscoped(), "exercises").
+		Order("exercises.id").
+		Find(&exercises)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	pull := dto.SyncPullDto{
+		Workouts:  dto.SyncChangesDto[models.Workout]{Updated: []models.Workout{}, Deleted: []uint{}},
+		Records:   dto.SyncChangesDto[dto.SyncRecordDto]{Updated: []dto.SyncRecordDto{}, Deleted: []uint{}},
+		Sets:      dto.SyncChangesDto[models.Set]{Updated: []models.Set{}, Deleted: []uint{}},
+		Exercises: dto.SyncChangesDto[models.Exercise]{Updated: []models.Exercise{}, Deleted: []uint{}},
+		Cursor:    encodeSyncCursor(window.Until),
+	}
+
+	for _, workout := range workouts {
+		if workout.DeletedAt.Valid {
+			pull.Workouts.Deleted = append(pull.Workouts.Deleted, workout.ID)
+			continue
+		}
+		pull.Workouts.Updated = append(pull.Workouts.Updated, workout)
+	}
+
+	for _, record := range records {
+		if record.DeletedAt.Valid {
+			pull.Records.Deleted = append(pull.Records.Deleted, record.ID)
+			continue
+		}
+		pull.Records.Updated = append(pull.Records.Updated, dto.SyncRecordDto{
+			ID:             record.ID,
+			ClientID:       record.ClientID,
+			CreatedAt:      record.CreatedAt,
+			UpdatedAt:      record.UpdatedAt,
+			Version:        record.Version,
+			ExerciseID:     record.ExerciseID,
+			Date:           record.Date,
+			WorkoutID:      record.WorkoutID,
+			Position:       record.Position,
+			WorkoutGroupID: record.WorkoutGroupID,
+			Placeholder:    record.Placeholder,
+		})
+	}
+
+	for _, set := range sets {
+		if set.DeletedAt.Valid {
+			pull.Sets.Deleted = append(pull.Sets.Deleted, set.ID)
+			continue
+		}
+		set.Weight = units.FromKilograms(set.Weight, unit)
+		pull.Sets.Updated = append(pull.Sets.Updated, set)
+	}
+
+	for _, exercise := range exercises {
+		if exercise.DeletedAt.Valid {
+			pull.Exercises.Deleted = append(pull.Exercises.Deleted, exercise.ID)
+			continue
+		}
+		imagesToURLs(&exercise, h.cfg.Server.BaseURL)
+		pull.Exercises.Updated = append(pull.Exercises.Updated, exercise)
+	}
+
+	return c.JSON(pull)
+}
+
+// PushChanges applies mutations made offline in order. Each one commits on
+// its own and is reported as applied, conflicting or rejected, a failed
+// mutation doesn't undo the ones before it.
+func (h *SyncHandler) PushChanges(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var pushDto dto.SyncPushDto
+	if err := c.BodyParser(&pushDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(pushDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	mutator := syncMutator{userID: userID, unit: unit, loc: loc, now: time.Now()}
+	results := make([]dto.SyncResultDto, len(pushDto.Mutations))
+	for i, mutation := range pushDto.Mutations {
+		results[i] = mutator.apply(h.db.DB, i, mutation)
+	}
+
+	return c.JSON(fiber.Map{
+		"results": results,
+	})
+}
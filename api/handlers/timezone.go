@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/nagy135/fitness-tracker/models"
+	"gorm.io/gorm"
+)
+
+// userLocation returns the timezone whose days the user's records and
+// workouts are grouped by
+func userLocation(db *gorm.DB, userID uint) (*time.Location, error) {
+	var user models.User
+	if err := db.Select("id, timezone").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if user.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(user.Timezone)
+}
+
+// localDay returns the YYYY-MM-DD day t falls on in loc
+func localDay(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("2006-01-02")
+}
+
+// calendarDay returns the day t falls on in its own location as UTC midnight,
+// the form day arithmetic in stats works with
+func calendarDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// localMidnight returns the instant a calendar day starts in loc
+func localMidnight(day time.Time, loc *time.Location) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+}
+
+// dayTables are the columns that hold the day something was performed on or
+// is due, plain days being stored as midnight in the user's timezone. Owner
+// selects the user's rows.
+var dayTables = []struct {
+	name      string
+	column    string
+	owner     string
+	versioned bool
+}{
+	{"workouts", "date", "user_id = @user", true},
+	{"records", "date", "user_id = @user", true},
+	{"personal_records", "date", "user_id = @user", false},
+	{"goal_entries", "date", "goal_id IN (SELECT id FROM goals WHERE user_id = @user)", false},
+	{"goals", "deadline", "user_id = @user", false},
+}
+
+// moveLocalDays keeps plain days on the same calendar day when the user's
+// timezone changes from one to the other, by moving dates at midnight in
+// from to midnight in to. Dates with a time of day are real instants and
+// stay where they are.
+func moveLocalDays(tx *gorm.DB, userID uint, from *time.Location, to *time.Location) error {
+	if from.String() == to.String() {
+		return nil
+	}
+
+	for _, table := range dayTables {
+		set := table.column + " = (" + table.column + " AT TIME ZONE @from)::date::timestamp AT TIME ZONE @to, updated_at = NOW()"
+		if table.versioned {
+			set += ", version = version + 1"
+		}
+		err := tx.Exec("UPDATE "+table.name+" SET "+set+`
+			WHERE `+table.owner+` AND `+table.column+` IS NOT NULL AND (`+table.column+` AT TIME ZONE @from)::time = '00:00'`,
+			map[string]any{"from": from.String(), "to": to.String(), "user": userID},
+		).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/internal/units"
+	"github.com/nagy135/fitness-tracker/models"
+	"gorm.io/gorm"
+)
+
+// userUnit returns the weight unit the user enters and reads weights in
+func userUnit(db *gorm.DB, userID uint) (units.Unit, error) {
+	var user models.User
+	if err := db.Select("id, unit").First(&user, userID).Error; err != nil {
+		return "", err
+	}
+	return units.ParseUnit(user.Unit)
+}
+
+// setDtosToKilograms converts the weights of incoming sets for storage
+func setDtosToKilograms(setDtos []dto.SetDto, unit units.Unit) {
+	for i := range setDtos {
+		setDtos[i].Weight = units.ToKilograms(setDtos[i].Weight, unit)
+	}
+}
+
+// templateDtoToKilograms converts the target weights of incoming template exercises for storage
+func templateDtoToKilograms(exerciseDtos []dto.WorkoutTemplateExerciseDto, unit units.Unit) {
+	for i := range exerciseDtos {
+		if exerciseDtos[i].TargetWeight != nil {
+			weight := units.ToKilograms(*exerciseDtos[i].TargetWeight, unit)
+			exerciseDtos[i].TargetWeight = &weight
+		}
+	}
+}
+
+// programDtoToKilograms converts the training maxes and increments of an incoming program for storage
+func programDtoToKilograms(programDto *dto.ProgramDto, unit units.Unit) {
+	for i := range programDto.TrainingMaxes {
+		programDto.TrainingMaxes[i].Weight = units.ToKilograms(programDto.TrainingMaxes[i].Weight, unit)
+	}
+	for i := range programDto.Rules {
+		programDto.Rules[i].Increment = units.ToKilograms(programDto.Rules[i].Increment, unit)
+	}
+}
+
+// recordToUnit converts the set weights of a loaded record for output. The
+// record must not be saved afterwards.
+func recordToUnit(record *models.Record, unit units.Unit) {
+	for i := range record.Sets {
+		record.Sets[i].Weight = units.FromKilograms(record.Sets[i].Weight, unit)
+	}
+}
+
+func recordsToUnit(records []models.Record, unit units.Unit) {
+	for i := range records {
+		recordToUnit(&records[i], unit)
+	}
+}
+
+// templateToUnit converts the target weights of a loaded template for output
+func templateToUnit(template *models.WorkoutTemplate, unit units.Unit) {
+	for i := range template.Exercises {
+		if template.Exercises[i].TargetWeight != nil {
+			weight := units.FromKilograms(*template.Exercises[i].TargetWeight, unit)
+			template.Exercises[i].TargetWeight = &weight
+		}
+	}
+}
+
+// programToUnit converts the training maxes and increments of a loaded program for output
+func programToUnit(program *models.Program, unit units.Unit) {
+	for i := range program.TrainingMaxes {
+		program.TrainingMaxes[i].Weight = units.FromKilograms(program.TrainingMaxes[i].Weight, unit)
+	}
+	for i := range program.Rules {
+		program.Rules[i].Increment = units.FromKilograms(program.Rules[i].Increment, unit)
+	}
+	for i := range program.Weeks {
+		for j := range program.Weeks[i].Days {
+			templateToUnit(&program.Weeks[i].Days[j].WorkoutTemplate, unit)
+		}
+	}
+}
+
+// personalRecordsToUnit converts PR weights and values for output, rep PRs
+// count reps and stay as they are
+func personalRecordsToUnit(prs []models.PersonalRecord, unit units.Unit) {
+	for i := range prs {
+		if prs[i].Weight != nil {
+			weight := units.FromKilograms(*prs[i].Weight, unit)
+			prs[i].Weight = &weight
+		}
+		if prs[i].Type == models.RepPR {
+			continue
+		}
+		prs[i].Value = units.FromKilograms(prs[i].Value, unit)
+		if prs[i].PreviousValue != nil {
+			previous := units.FromKilograms(*prs[i].PreviousValue, unit)
+			prs[i].PreviousValue = &previous
+		}
+	}
+}
+
+// volumesToUnit converts daily volumes keyed by day for output
+func volumesToUnit(volumes map[string]decimal.Decimal, unit units.Unit) {
+	for day, volume := range volumes {
+		volumes[day] = units.FromKilograms(volume, unit)
+	}
+}
+
+// prescriptionToUnit converts prescribed weights for output, rounding them
+// to what can be loaded on a bar in that unit
+func prescriptionToUnit(today *dto.ProgramTodayDto, unit units.Unit) {
+	if today == nil {
+		return
+	}
+	for i := range today.Exercises {
+		exercise := &today.Exercises[i]
+		if exercise.Weight != nil {
+			weight := units.RoundToPlates(units.FromKilograms(*exercise.Weight, unit), unit)
+			exercise.Weight = &weight
+		}
+		if exercise.TrainingMax != nil {
+			trainingMax := units.FromKilograms(*exercise.TrainingMax, unit)
+			exercise.TrainingMax = &trainingMax
+		}
+	}
+}
+
+// programResultToUnit converts the training maxes of a completed program day for output
+func programResultToUnit(result *dto.ProgramSessionResultDto, unit units.Unit) {
+	if result == nil {
+		return
+	}
+	for i := range result.Exercises {
+		exercise := &result.Exercises[i]
+		if exercise.PreviousMax != nil {
+			previous := units.FromKilograms(*exercise.PreviousMax, unit)
+			exercise.PreviousMax = &previous
+		}
+		if exercise.TrainingMax != nil {
+			trainingMax := units.FromKilograms(*exercise.TrainingMax, unit)
+			exercise.TrainingMax = &trainingMax
+		}
+	}
+	prescriptionToUnit(result.Next, unit)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Records, workouts and exercises carry a version that is served as their
+// ETag. Edits that take an If-Match claim the next version with claimVersion,
+// every other write to the row moves it on with bumpVersion, so a client
+// holding an older ETag can't overwrite what changed since.
+
+var errVersionConflict = errors.New("Changed by another request, reload it and try again")
+
+// etag is the entity tag of a resource version
+func etag(version uint) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// setETag tags the response with the version of the resource it returns
+func setETag(c *fiber.Ctx, version uint) {
+	c.Set(fiber.HeaderETag, etag(version))
+}
+
+// checkIfMatch makes sure the client edits the current version of a resource.
+// A missing If-Match is answered with 428 and an outdated one with 412.
+// Usage: if ok, err := checkIfMatch(c, record.Version); !ok { return err }
+func checkIfMatch(c *fiber.Ctx, version uint) (bool, error) {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		return false, c.Status(fiber.StatusPreconditionRequired).JSON(fiber.Map{
+			"error": "If-Match header with the ETag of the resource is required",
+		})
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true, nil
+		}
+	}
+
+	setETag(c, version)
+	return false, c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+		"error": errVersionConflict.Error(),
+	})
+}
+
+// bumpVersion is added to the columns a write without If-Match changes, e.g.
+// Updates(map[string]interface{}{"position": 2, "version": bumpVersion})
+var bumpVersion = gorm.Expr("version + 1")
+
+// claimVersion moves a row from version to the next one, failing with
+// errVersionConflict when another request already did. Run it inside the
+// transaction that writes the change so concurrent writers can't both pass.
+func claimVersion(tx *gorm.DB, model any, id uint, version uint) error {
+	result := tx.Model(model).
+		Where("id = ? AND version = ?", id, version).
+		Update("version", bumpVersion)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
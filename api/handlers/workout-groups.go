@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+)
+
+// CreateWorkoutGroup marks records of a workout as a superset, giant set or
+// circuit and moves them next to each other in the given order
+func (h *WorkoutHandler) CreateWorkoutGroup(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	workoutID, err := c.ParamsInt("id")
+	if err != nil || workoutID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workout ID",
+		})
+	}
+
+	var groupDto dto.WorkoutGroupDto
+	if err := c.BodyParser(&groupDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(groupDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	if len(uniqueIDs(groupDto.RecordIDs)) != len(groupDto.RecordIDs) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "recordIds must not contain duplicates",
+		})
+	}
+
+	switch {
+	case groupDto.Type == models.Superset && len(groupDto.RecordIDs) != 2:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "A superset groups exactly 2 records",
+		})
+	case groupDto.Type == models.GiantSet && len(groupDto.RecordIDs) < 3:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "A giant set groups at least 3 records",
+		})
+	}
+
+	workout, err := findUserWorkout(h.db.DB, uint(workoutID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workout not found or doesn't belong to you",
+		})
+	}
+
+	var records []models.Record
+	if err := h.db.DB.Where("workout_id = ?", workout.ID).Order("position ASC, created_at ASC").Find(&records).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	grouped := make(map[uint]bool, len(groupDto.RecordIDs))
+	for _, id := range groupDto.RecordIDs {
+		grouped[id] = true
+	}
+
+	var found int
+	for _, record := range records {
+		if grouped[record.ID] {
+			found++
+		}
+	}
+	if found != len(groupDto.RecordIDs) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "All records must belong to this workout",
+		})
+	}
+
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		var labels []string
+		if err := tx.Model(&models.WorkoutGroup{}).Where("workout_id = ?", workout.ID).Pluck("label", &labels).Error; err != nil {
+			return err
+		}
+
+		group := models.WorkoutGroup{
+			WorkoutID: workout.ID,
+			Type:      groupDto.Type,
+			Label:     nextGroupLabel(labels),
+		}
+		if groupDto.Label != nil {
+			group.Label = *groupDto.Label
+		}
+
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Record{}).Where("id IN ?", groupDto.RecordIDs).Updates(map[string]interface{}{
+			"workout_group_id": group.ID,
+			"version":          bumpVersion,
+		}).Error; err != nil {
+			return err
+		}
+
+		// The group takes the place of its first member, the rest keep their relative order
+		var order []uint
+		for _, record := range records {
+			if !grouped[record.ID] {
+				order = append(order, record.ID)
+			} else if record.ID == firstInOrder(records, grouped) {
+				order = append(order, groupDto.RecordIDs...)
+			}
+		}
+
+		for position, id := range order {
+			if err := tx.Model(&models.Record{}).Where("id = ?", id).Updates(map[string]interface{}{
+				"position": position,
+				"version":  bumpVersion,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		// Records may have been pulled out of groups that are now too small
+		return pruneWorkoutGroups(tx, workout.ID)
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create group",
+		})
+	}
+
+	detail, err := h.loadWorkoutDetail(workout.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(detail)
+}
+
+// DeleteWorkoutGroup ungroups records, they keep their positions
+func (h *WorkoutHandler) DeleteWorkoutGroup(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	workoutID, err := c.ParamsInt("id")
+	if err != nil || workoutID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workout ID",
+		})
+	}
+
+	groupID, err := c.ParamsInt("groupId")
+	if err != nil || groupID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid group ID",
+		})
+	}
+
+	workout, err := findUserWorkout(h.db.DB, uint(workoutID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workout not found or doesn't belong to you",
+		})
+	}
+
+	var group models.WorkoutGroup
+	if err := h.db.DB.Where("id = ? AND workout_id = ?", groupID, workout.ID).First(&group).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Group not found in this workout",
+		})
+	}
+
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Record{}).Where("workout_group_id = ?", group.ID).Updates(map[string]interface{}{
+			"workout_group_id": nil,
+			"version":          bumpVersion,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&group).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete group",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// pruneWorkoutGroups removes groups of a workout left with fewer than two records
+func pruneWorkoutGroups(tx *gorm.DB, workoutID uint) error {
+	var groups []models.WorkoutGroup
+	if err := tx.Where("workout_id = ?", workoutID).Find(&groups).Error; err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		var members int64
+		if err := tx.Model(&models.Record{}).Where("workout_group_id = ? AND workout_id = ?", group.ID, workoutID).Count(&members).Error; err != nil {
+			return err
+		}
+
+		if members >= 2 {
+			continue
+		}
+
+		if err := tx.Model(&models.Record{}).Where("workout_group_id = ?", group.ID).Updates(map[string]interface{}{
+			"workout_group_id": nil,
+			"version":          bumpVersion,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&group).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func firstInOrder(records []models.Record, ids map[uint]bool) uint {
+	for _, record := range records {
+		if ids[record.ID] {
+			return record.ID
+		}
+	}
+	return 0
+}
+
+// groupLabel names groups A, B, ... Z, then A2, B2 and so on
+func groupLabel(index int) string {
+	label := string(rune('A' + index%26))
+	if round := index / 26; round > 0 {
+		label += fmt.Sprint(round + 1)
+	}
+	return label
+}
+
+// groupLabelIndex reverses groupLabel, custom labels return false
+func groupLabelIndex(label string) (int, bool) {
+	if label == "" || label[0] < 'A' || label[0] > 'Z' {
+		return 0, false
+	}
+
+	round := 1
+	if len(label) > 1 {
+		parsed, err := strconv.Atoi(label[1:])
+		if err != nil || parsed < 2 || fmt.Sprint(parsed) != label[1:] {
+			return 0, false
+		}
+		round = parsed
+	}
+	return (round-1)*26 + int(label[0]-'A'), true
+}
+
+// nextGroupLabel follows the highest generated label in use, so deleting a
+// group never hands its label out to a new one while later groups remain
+func nextGroupLabel(labels []string) string {
+	next := 0
+	for _, label := range labels {
+		if index, ok := groupLabelIndex(label); ok && index >= next {
+			next = index + 1
+		}
+	}
+	return groupLabel(next)
+}
@@ -0,0 +1,470 @@
+package handlers
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/internal/units"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errWorkoutStarted = errors.New("Workout has already been started")
+
+var errSessionActive = errors.New("Another workout session is still active")
+
+func (h *WorkoutHandler) GetActiveWorkout(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var workout models.Workout
+	result := h.db.DB.
+		Where("user_id = ? AND started_at IS NOT NULL AND finished_at IS NULL", userID).
+		Order("started_at DESC").
+		First(&workout)
+	if result.Error != nil {
+		return c.JSON(fiber.Map{
+			"workout": nil,
+		})
+	}
+
+	detail, err := h.loadWorkoutDetail(workout.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"workout": detail,
+	})
+}
+
+func (h *WorkoutHandler) StartWorkout(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	workoutID, err := c.ParamsInt("id")
+	if err != nil || workoutID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workout ID",
+		})
+	}
+
+	workout, err := findUserWorkout(h.db.DB, uint(workoutID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workout not found or doesn't belong to you",
+		})
+	}
+
+	if workout.StartedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": errWorkoutStarted.Error(),
+		})
+	}
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"started_at": now,
+		"version":    bumpVersion,
+	}
+	if workout.Date == nil {
+		updates["date"] = localMidnight(calendarDay(now.In(loc)), loc)
+	}
+
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the user makes concurrent starts wait, so only one session
+		// can be running at a time
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		var activeCount int64
+		result := tx.Model(&models.Workout{}).
+			Where("user_id = ? AND started_at IS NOT NULL AND finished_at IS NULL", userID).
+			Count(&activeCount)
+		if result.Error != nil {
+			return result.Error
+		}
+		if activeCount > 0 {
+			return errSessionActive
+		}
+
+		result = tx.Model(workout).Where("started_at IS NULL").Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errWorkoutStarted
+		}
+		return nil
+	})
+	if errors.Is(err, errSessionActive) || errors.Is(err, errWorkoutStarted) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	detail, err := h.loadWorkoutDetail(workout.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(detail)
+}
+
+func (h *WorkoutHandler) FinishWorkout(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	workoutID, err := c.ParamsInt("id")
+	if err != nil || workoutID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workout ID",
+		})
+	}
+
+	workout, err := findUserWorkout(h.db.DB, uint(workoutID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workout not found or doesn't belong to you",
+		})
+	}
+
+	if workout.StartedAt == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Workout has not been started",
+		})
+	}
+
+	if workout.FinishedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Workout has already been finished",
+		})
+	}
+
+	// Finishing a program workout also completes its program day
+	var programResult *dto.ProgramSessionResultDto
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(workout).Updates(map[string]interface{}{
+			"finished_at": time.Now(),
+			"version":     bumpVersion,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if workout.ProgramID == nil {
+			return nil
+		}
+
+		program, err := lockUserProgram(tx, *workout.ProgramID, userID)
+		if errors.Is(err, errProgramNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if prescribeNextDay(program) == nil {
+			return nil
+		}
+
+		// Only when the workout still matches the day the program expects
+		programResult, err = completeProgramDay(tx, program, workout)
+		if errors.Is(err, errProgramDayCompleted) || errors.Is(err, errProgramDayMismatch) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	detail, err := h.loadWorkoutDetail(workout.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	programResultToUnit(programResult, unit)
+	detail.Program = programResult
+
+	return c.JSON(detail)
+}
+
+// AddSessionSet appends a set to the active session, reusing the workout's
+// record for the exercise or creating a new one at the end of the workout
+func (h *WorkoutHandler) AddSessionSet(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	workoutID, err := c.ParamsInt("id")
+	if err != nil || workoutID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workout ID",
+		})
+	}
+
+	var sessionSetDto dto.SessionSetDto
+	if err := c.BodyParser(&sessionSetDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(sessionSetDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	workout, err := findUserWorkout(h.db.DB, uint(workoutID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workout not found or doesn't belong to you",
+		})
+	}
+
+	if workout.StartedAt == nil || workout.FinishedAt != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Workout session is not active",
+		})
+	}
+
+	if ok, err := checkExerciseReference(c, h.db.DB, "ExerciseID", sessionSetDto.ExerciseID); !ok {
+		return err
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	now := time.Now()
+	var set models.Set
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		var record models.Record
+		result := tx.
+			Where("workout_id = ? AND exercise_id = ?", workout.ID, sessionSetDto.ExerciseID).
+			Order("position DESC").
+			First(&record)
+		if result.Error != nil {
+			position, err := nextWorkoutPosition(tx, workout.ID)
+			if err != nil {
+				return err
+			}
+
+			record = models.Record{
+				ExerciseID: sessionSetDto.ExerciseID,
+				UserID:     userID,
+				Date:       workout.Date,
+				WorkoutID:  &workout.ID,
+				Position:   position,
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+		} else if record.Placeholder {
+			// The first real set replaces the sets pre-filled from a template
+			if err := tx.Where("record_id = ?", record.ID).Delete(&models.Set{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&record).Update("placeholder", false).Error; err != nil {
+				return err
+			}
+		}
+
+		set = models.Set{
+			Reps:        sessionSetDto.Reps,
+			Weight:      units.ToKilograms(sessionSetDto.Weight, unit),
+			PerformedAt: &now,
+			RecordID:    record.ID,
+		}
+		if err := tx.Create(&set).Error; err != nil {
+			return err
+		}
+
+		// Edits made against the record before this set no longer apply
+		if err := tx.Model(&record).Update("version", bumpVersion).Error; err != nil {
+			return err
+		}
+
+		prs, err := storeRecordPRs(tx, record.ID)
+		if err != nil {
+			return err
+		}
+		for _, pr := range prs {
+			if pr.SetID != nil && *pr.SetID == set.ID {
+				set.PersonalRecords = append(set.PersonalRecords, pr.Type)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	set.Weight = units.FromKilograms(set.Weight, unit)
+
+	return c.Status(fiber.StatusCreated).JSON(set)
+}
+
+// loadWorkoutDetail loads a workout with its ordered records and session stats
+func (h *WorkoutHandler) loadWorkoutDetail(workoutID uint, userID uint) (*dto.WorkoutDetailDto, error) {
+	var workout models.Workout
+	result := h.db.DB.
+		Preload("Records", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position ASC, created_at ASC")
+		}).
+		Preload("Records.Exercise").
+		Preload("Records.Sets").
+		Preload("Groups").
+		Where("id = ? AND user_id = ?", workoutID, userID).
+		First(&workout)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	recordsToUnit(workout.Records, unit)
+
+	return &dto.WorkoutDetailDto{
+		Workout: workout,
+		Session: sessionStats(workout, workout.Records, time.Now()),
+	}, nil
+}
+
+// sessionStats computes duration, density and rest statistics of a live
+// session. Returns nil for workouts that were never started.
+func sessionStats(workout models.Workout, records []models.Record, now time.Time) *dto.WorkoutSessionStatsDto {
+	if workout.StartedAt == nil {
+		return nil
+	}
+
+	end := now
+	if workout.FinishedAt != nil {
+		end = *workout.FinishedAt
+	}
+
+	stats := &dto.WorkoutSessionStatsDto{
+		Active:          workout.FinishedAt == nil,
+		DurationMinutes: end.Sub(*workout.StartedAt).Minutes(),
+	}
+
+	var performed []time.Time
+	for _, record := range records {
+		if record.Placeholder {
+			continue
+		}
+
+		var recordVolume decimal.Decimal
+		for _, set := range record.Sets {
+			recordVolume += set.Weight.MulInt(set.Reps)
+			stats.SetCount++
+			if set.PerformedAt != nil {
+				performed = append(performed, *set.PerformedAt)
+			}
+		}
+		stats.Volume += recordVolume.MulFloat(float64(record.Exercise.TotalWeightMultiplier))
+	}
+
+	if stats.DurationMinutes > 0 {
+		stats.Density = decimal.RoundRatio(stats.Volume.Float64() / stats.DurationMinutes)
+	}
+
+	stats.Rest = restStats(restIntervals(performed))
+
+	return stats
+}
+
+// restIntervals returns the gaps in seconds between consecutive set timestamps
+func restIntervals(performed []time.Time) []float64 {
+	sort.Slice(performed, func(i, j int) bool {
+		return performed[i].Before(performed[j])
+	})
+
+	var intervals []float64
+	for i := 1; i < len(performed); i++ {
+		intervals = append(intervals, performed[i].Sub(performed[i-1]).Seconds())
+	}
+	return intervals
+}
+
+func restStats(intervals []float64) *dto.RestStatsDto {
+	if len(intervals) == 0 {
+		return nil
+	}
+
+	stats := &dto.RestStatsDto{
+		Count:      len(intervals),
+		MinSeconds: intervals[0],
+		MaxSeconds: intervals[0],
+	}
+
+	var total float64
+	for _, interval := range intervals {
+		total += interval
+		stats.MinSeconds = min(stats.MinSeconds, interval)
+		stats.MaxSeconds = max(stats.MaxSeconds, interval)
+	}
+	stats.AverageSeconds = total / float64(len(intervals))
+
+	return stats
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"gorm.io/gorm"
+)
+
+// Days of records and workouts as they are grouped in stats, both take the
+// user's timezone name as their argument
+const (
+	recordDaySQL  = "TO_CHAR(COALESCE(r.date, r.created_at) AT TIME ZONE ?, 'YYYY-MM-DD')"
+	workoutDaySQL = "TO_CHAR(COALESCE(w.date, w.created_at) AT TIME ZONE ?, 'YYYY-MM-DD')"
+)
+
+// recordStatsTimeSQL is when a record counts in stats, its workout's date
+// when it's part of a workout w that isn't deleted
+const recordStatsTimeSQL = "COALESCE(w.date, w.created_at, r.date, r.created_at)"
+
+// multiplierSQL is the exercise's weight multiplier as NUMERIC, so volumes
+// are summed exactly and rounded once at the end
+const multiplierSQL = "e.total_weight_multiplier::numeric"
+
+// statsRange limits stats queries to [From, To), either bound is optional.
+// Days are grouped in Location.
+type statsRange struct {
+	From     *time.Time
+	To       *time.Time
+	Location *time.Location
+}
+
+// newStatsRange builds a range from inclusive YYYY-MM-DD bounds in loc
+func newStatsRange(from string, to string, loc *time.Location) statsRange {
+	statsRange := statsRange{Location: loc}
+	if from != "" {
+		if parsed, err := time.ParseInLocation("2006-01-02", from, loc); err == nil {
+			statsRange.From = &parsed
+		}
+	}
+	if to != "" {
+		if parsed, err := time.ParseInLocation("2006-01-02", to, loc); err == nil {
+			next := parsed.AddDate(0, 0, 1)
+			statsRange.To = &next
+		}
+	}
+	return statsRange
+}
+
+// timezone is the timezone name day expressions take, UTC when not set
+func (r statsRange) timezone() string {
+	if r.Location == nil {
+		return "UTC"
+	}
+	return r.Location.String()
+}
+
+// where returns SQL conditions and arguments restricting a timestamp expression to the range
+func (r statsRange) where(expr string) (string, []any) {
+	sql := ""
+	var args []any
+	if r.From != nil {
+		sql += " AND " + expr + " >= ?"
+		args = append(args, *r.From)
+	}
+	if r.To != nil {
+		sql += " AND " + expr + " < ?"
+		args = append(args, *r.To)
+	}
+	return sql, args
+}
+
+// queryDailyVolumes sums weight × reps × TotalWeightMultiplier per day
+func queryDailyVolumes(db *gorm.DB, userID uint, statsRange statsRange) (map[string]decimal.Decimal, error) {
+	rangeSQL, rangeArgs := statsRange.where("COALESCE(r.date, r.created_at)")
+
+	var rows []struct {
+		Day         string
+		TotalWeight decimal.Decimal
+	}
+	err := db.Raw(`
+		SELECT `+recordDaySQL+` AS day,
+			ROUND(COALESCE(SUM(s.weight * s.reps * `+multiplierSQL+`), 0), 3) AS total_weight
+		FROM records r
+		JOIN exercises e ON e.id = r.exercise_id
+		LEFT JOIN sets s ON s.record_id = r.id AND s.deleted_at IS NULL
+		WHERE r.user_id = ? AND r.deleted_at IS NULL AND r.placeholder = false`+rangeSQL+`
+		GROUP BY day
+	`, append([]any{statsRange.timezone(), userID}, rangeArgs...)...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make(map[string]decimal.Decimal, len(rows))
+	for _, row := range rows {
+		volumes[row.Day] = row.TotalWeight
+	}
+	return volumes, nil
+}
+
+// workoutStat is one entry of workout stats: a workout, or the records of a
+// day that aren't part of any workout
+type workoutStat struct {
+	WorkoutID *uint
+	Day       string
+	Name      string
+	Start     time.Time
+	Volume    decimal.Decimal
+
+	// Live session duration, unfinished sessions count up to now
+	Minutes float64
+}
+
+// queryWorkoutStats sums the volume of every workout in the range, records
+// outside of workouts are summed per day. Entries are sorted newest first.
+func queryWorkoutStats(db *gorm.DB, userID uint, statsRange statsRange) ([]workoutStat, error) {
+	workoutRangeSQL, workoutRangeArgs := statsRange.where("COALESCE(w.date, w.created_at)")
+	recordRangeSQL, recordRangeArgs := statsRange.where("COALESCE(r.date, r.created_at)")
+
+	var workouts []workoutStat
+	err := db.Raw(`
+		SELECT w.id AS workout_id, `+workoutDaySQL+` AS day, w.label AS name,
+			COALESCE(w.started_at, w.date, w.created_at) AS start,
+			ROUND(COALESCE(v.volume, 0), 3) AS volume,
+			COALESCE(EXTRACT(EPOCH FROM COALESCE(w.finished_at, NOW()) - w.started_at) / 60, 0)::float8 AS minutes
+		FROM workouts w
+		LEFT JOIN (
+			SELECT r.workout_id, SUM(s.weight * s.reps * `+multiplierSQL+`) AS volume
+			FROM records r
+			JOIN exercises e ON e.id = r.exercise_id
+			JOIN sets s ON s.record_id = r.id AND s.deleted_at IS NULL
+			WHERE r.user_id = ? AND r.deleted_at IS NULL AND r.placeholder = false AND r.workout_id IS NOT NULL
+			GROUP BY r.workout_id
+		) v ON v.workout_id = w.id
+		WHERE w.user_id = ? AND w.deleted_at IS NULL`+workoutRangeSQL+`
+	`, append([]any{statsRange.timezone(), userID, userID}, workoutRangeArgs...)...).Scan(&workouts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var days []workoutStat
+	err = db.Raw(`
+		SELECT `+recordDaySQL+` AS day,
+			MIN(COALESCE(r.date, r.created_at)) AS start,
+			ROUND(COALESCE(SUM(s.weight * s.reps * `+multiplierSQL+`), 0), 3) AS volume
+		FROM records r
+		JOIN exercises e ON e.id = r.exercise_id
+		LEFT JOIN workouts w ON w.id = r.workout_id AND w.deleted_at IS NULL
+		LEFT JOIN sets s ON s.record_id = r.id AND s.deleted_at IS NULL
+		WHERE r.user_id = ? AND r.deleted_at IS NULL AND r.placeholder = false AND w.id IS NULL`+recordRangeSQL+`
+		GROUP BY day
+	`, append([]any{statsRange.timezone(), userID}, recordRangeArgs...)...).Scan(&days).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := append(workouts, days...)
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Day != stats[j].Day {
+			return stats[i].Day > stats[j].Day
+		}
+		return stats[i].Start.After(stats[j].Start)
+	})
+	return stats, nil
+}
+
+// queryWorkoutRests computes rest between sets per live session in the range
+func queryWorkoutRests(db *gorm.DB, userID uint, statsRange statsRange) (map[uint]*dto.RestStatsDto, error) {
+	rangeSQL, rangeArgs := statsRange.where("COALESCE(w.date, w.created_at)")
+
+	var rows []struct {
+		WorkoutID      uint
+		Count          int
+		AverageSeconds float64
+		MinSeconds     float64
+		MaxSeconds     float64
+	}
+	err := db.Raw(`
+		SELECT workout_id, COUNT(gap) AS count, AVG(gap)::float8 AS average_seconds,
+			MIN(gap)::float8 AS min_seconds, MAX(gap)::float8 AS max_seconds
+		FROM (
+			SELECT w.id AS workout_id,
+				EXTRACT(EPOCH FROM s.performed_at - LAG(s.performed_at) OVER (PARTITION BY w.id ORDER BY s.performed_at)) AS gap
+			FROM workouts w
+			JOIN records r ON r.workout_id = w.id AND r.deleted_at IS NULL AND r.placeholder = false
+			JOIN sets s ON s.record_id = r.id AND s.deleted_at IS NULL AND s.performed_at IS NOT NULL
+			WHERE w.user_id = ? AND w.deleted_at IS NULL AND w.started_at IS NOT NULL`+rangeSQL+`
+		) gaps
+		WHERE gap IS NOT NULL
+		GROUP BY workout_id
+	`, append([]any{userID}, rangeArgs...)...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rests := make(map[uint]*dto.RestStatsDto, len(rows))
+	for _, row := range rows {
+		rests[row.WorkoutID] = &dto.RestStatsDto{
+			Count:          row.Count,
+			AverageSeconds: row.AverageSeconds,
+			MinSeconds:     row.MinSeconds,
+			MaxSeconds:     row.MaxSeconds,
+		}
+	}
+	return rests, nil
+}
+
+// dayRecordsSQL ranks the records of a range in the order they were
+// performed: by their workout's start, their position in it, then creation.
+// Records outside a workout count from their creation time. Records of a
+// workout are in the range when their workout is, see recordStatsTimeSQL.
+const dayRecordsSQL = `
+	WITH day_records AS (
+		SELECT r.id, r.exercise_id, r.workout_group_id, w.id AS workout_id,
+			ROW_NUMBER() OVER (ORDER BY
+				COALESCE(w.started_at, w.created_at, r.created_at),
+				CASE WHEN w.id IS NULL THEN 0 ELSE r.position END,
+				r.created_at, r.id) AS rank
+		FROM records r
+		LEFT JOIN workouts w ON w.id = r.workout_id AND w.deleted_at IS NULL
+		WHERE r.user_id = ? AND r.deleted_at IS NULL AND r.placeholder = false`
+
+type dayExercise struct {
+	WorkoutID    *uint
+	ExerciseName string
+	TotalWeight  decimal.Decimal
+	GroupType    *string
+	GroupLabel   *string
+}
+
+type daySet struct {
+	WorkoutID    *uint
+	ExerciseName string
+	Reps         int
+	Weight       decimal.Decimal
+}
+
+// queryDayExercises sums the volume of each exercise per workout in the range,
+// in the order the exercises were first performed, with the group of their
+// first record. Records outside of workouts have no workout ID.
+func queryDayExercises(db *gorm.DB, userID uint, statsRange statsRange) ([]dayExercise, error) {
+	rangeSQL, rangeArgs := statsRange.where(recordStatsTimeSQL)
+
+	var exercises []dayExercise
+	err := db.Raw(dayRecordsSQL+rangeSQL+`
+		)
+		SELECT dr.workout_id, e.name AS exercise_name,
+			ROUND(COALESCE(SUM(v.volume * `+multiplierSQL+`), 0), 3) AS total_weight,
+			(ARRAY_AGG(g.type ORDER BY dr.rank))[1] AS group_type,
+			(ARRAY_AGG(g.label ORDER BY dr.rank))[1] AS group_label
+		FROM day_records dr
+		JOIN exercises e ON e.id = dr.exercise_id
+		LEFT JOIN workout_groups g ON g.id = dr.workout_group_id AND g.deleted_at IS NULL
+		LEFT JOIN (
+			SELECT s.record_id, SUM(s.weight * s.reps) AS volume
+			FROM sets s
+			WHERE s.deleted_at IS NULL AND s.record_id IN (SELECT id FROM day_records)
+			GROUP BY s.record_id
+		) v ON v.record_id = dr.id
+		GROUP BY dr.workout_id, e.name
+		ORDER BY MIN(dr.rank)
+	`, append([]any{userID}, rangeArgs...)...).Scan(&exercises).Error
+	return exercises, err
+}
+
+// queryDaySets lists the sets of the range in the order they were performed
+func queryDaySets(db *gorm.DB, userID uint, statsRange statsRange) ([]daySet, error) {
+	rangeSQL, rangeArgs := statsRange.where(recordStatsTimeSQL)
+
+	var sets []daySet
+	err := db.Raw(dayRecordsSQL+rangeSQL+`
+		)
+		SELECT dr.workout_id, e.name AS exercise_name, s.reps, s.weight
+		FROM day_records dr
+		JOIN exercises e ON e.id = dr.exercise_id
+		JOIN sets s ON s.record_id = dr.id AND s.deleted_at IS NULL
+		ORDER BY dr.rank, s.id
+	`, append([]any{userID}, rangeArgs...)...).Scan(&sets).Error
+	return sets, err
+}
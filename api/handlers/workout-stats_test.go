@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/database"
+	"github.com/nagy135/fitness-tracker/internal/config"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The stats benchmarks run against a real Postgres seeded with several years
+// of training. They write to the database, so they only run when
+// BENCH_DB_NAME names a throwaway one, e.g. with the local compose database:
+//
+//	docker compose -f docker-compose.local.yml up -d db
+//	BENCH_DB_NAME=fitness_bench DB_HOST=localhost DB_PORT=15432 \
+//		go test -run '^$' -bench . ./handlers
+const (
+	benchUserName     = "stats-benchmark"
+	benchYears        = 3
+	benchSessionsWeek = 4
+	benchExercises    = 40
+	benchRecords      = 5 // per workout
+	benchSets         = 4 // per record
+)
+
+var (
+	benchOnce   sync.Once
+	benchDB     *gorm.DB
+	benchUserID uint
+	benchErr    error
+)
+
+// seededStatsDB connects to the benchmark database and seeds it on first use,
+// an existing benchmark user is reused
+func seededStatsDB(b *testing.B) (*gorm.DB, uint) {
+	b.Helper()
+
+	name := os.Getenv("BENCH_DB_NAME")
+	if name == "" {
+		b.Skip("BENCH_DB_NAME not set, stats benchmarks need a throwaway Postgres database")
+	}
+
+	benchOnce.Do(func() {
+		cfg := config.LoadConfig()
+		cfg.Database.Name = name
+
+		instance, err := database.ConnectDB(cfg)
+		if err != nil {
+			benchErr = err
+			return
+		}
+		benchDB = instance.DB.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+		benchUserID, benchErr = seedStatsHistory(benchDB)
+	})
+	if benchErr != nil {
+		b.Fatal(benchErr)
+	}
+
+	return benchDB, benchUserID
+}
+
+// seedStatsHistory logs benchYears of workouts with records and sets for one
+// user, a few of them as live sessions with timed sets
+func seedStatsHistory(db *gorm.DB) (uint, error) {
+	var user models.User
+	if err := db.Where("name = ?", benchUserName).First(&user).Error; err == nil {
+		return user.ID, nil
+	}
+
+	user = models.User{Name: benchUserName, Unit: "kg", Timezone: "Europe/Bratislava"}
+	if err := db.Create(&user).Error; err != nil {
+		return 0, err
+	}
+
+	exercises := make([]models.Exercise, benchExercises)
+	for i := range exercises {
+		exercises[i] = models.Exercise{Name: "Benchmark exercise " + string(rune('A'+i%26)) + string(rune('a'+i/26))}
+	}
+	if err := db.Create(&exercises).Error; err != nil {
+		return 0, err
+	}
+
+	random := rand.New(rand.NewSource(1))
+	start := time.Now().AddDate(-benchYears, 0, 0).Truncate(24 * time.Hour)
+	sessions := benchYears * 52 * benchSessionsWeek
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := 0; i < sessions; i++ {
+			date := start.Add(time.Duration(i*7*24/benchSessionsWeek) * time.Hour).Add(17 * time.Hour)
+			workout := models.Workout{UserID: user.ID, Label: "Session", Date: &date}
+
+			// Every tenth workout was logged live
+			live := i%10 == 0
+			if live {
+				finished := date.Add(70 * time.Minute)
+				workout.StartedAt = &date
+				workout.FinishedAt = &finished
+			}
+			if err := tx.Create(&workout).Error; err != nil {
+				return err
+			}
+
+			records := make([]models.Record, benchRecords)
+			for j := range records {
+				records[j] = models.Record{
+					ExerciseID: exercises[random.Intn(len(exercises))].ID,
+					UserID:     user.ID,
+					Date:       &date,
+					WorkoutID:  &workout.ID,
+					Position:   j,
+				}
+			}
+			if err := tx.Create(&records).Error; err != nil {
+				return err
+			}
+
+			var sets []models.Set
+			for j, record := range records {
+				for k := 0; k < benchSets; k++ {
+					set := models.Set{
+						Reps:     5 + random.Intn(6),
+						Weight:   decimal.FromInt(int64(40 + random.Intn(80))),
+						RecordID: record.ID,
+					}
+					if live {
+						performed := date.Add(time.Duration(j*benchSets+k) * 3 * time.Minute)
+						set.PerformedAt = &performed
+					}
+					sets = append(sets, set)
+				}
+			}
+			if err := tx.Create(&sets).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
+// benchStatsRanges are the ranges stats are usually asked for
+func benchStatsRanges(loc *time.Location) map[string]statsRange {
+	today := time.Now().In(loc).Format("2006-01-02")
+	return map[string]statsRange{
+		"all":   newStatsRange("", "", loc),
+		"year":  newStatsRange(time.Now().In(loc).AddDate(-1, 0, 0).Format("2006-01-02"), today, loc),
+		"month": newStatsRange(time.Now().In(loc).AddDate(0, -1, 0).Format("2006-01-02"), today, loc),
+	}
+}
+
+func BenchmarkDailyVolumes(b *testing.B) {
+	db, userID := seededStatsDB(b)
+	loc, _ := userLocation(db, userID)
+
+	for name, statsRange := range benchStatsRanges(loc) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := queryDailyVolumes(db, userID, statsRange); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWorkoutStats(b *testing.B) {
+	db, userID := seededStatsDB(b)
+	loc, _ := userLocation(db, userID)
+
+	for name, statsRange := range benchStatsRanges(loc) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := queryWorkoutStats(db, userID, statsRange); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWorkoutRests(b *testing.B) {
+	db, userID := seededStatsDB(b)
+	loc, _ := userLocation(db, userID)
+
+	for name, statsRange := range benchStatsRanges(loc) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := queryWorkoutRests(db, userID, statsRange); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDayStats covers the queries behind GET /workouts/stats/:date
+func BenchmarkDayStats(b *testing.B) {
+	db, userID := seededStatsDB(b)
+	loc, _ := userLocation(db, userID)
+
+	var lastWorkout models.Workout
+	if err := db.Where("user_id = ?", userID).Order("date DESC").First(&lastWorkout).Error; err != nil {
+		b.Fatal(err)
+	}
+	day := localDay(*lastWorkout.Date, loc)
+	statsRange := newStatsRange(day, day, loc)
+
+	for i := 0; i < b.N; i++ {
+		if _, err := queryDayExercises(db, userID, statsRange); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := queryDaySets(db, userID, statsRange); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nagy135/fitness-tracker/database"
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/models"
+	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
+)
+
+type WorkoutTemplateHandler struct {
+	db *database.DBInstance
+}
+
+func NewWorkoutTemplateHandler(db *database.DBInstance) *WorkoutTemplateHandler {
+	return &WorkoutTemplateHandler{db: db}
+}
+
+func (h *WorkoutTemplateHandler) GetWorkoutTemplates(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var templates []models.WorkoutTemplate
+	result := h.db.DB.
+		Preload("Exercises", orderByPosition).
+		Preload("Exercises.Exercise").
+		Where("user_id = ?", userID).
+		Find(&templates)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": result.Error.Error(),
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	for i := range templates {
+		templateToUnit(&templates[i], unit)
+	}
+
+	return c.JSON(fiber.Map{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+func (h *WorkoutTemplateHandler) GetWorkoutTemplate(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	templateID, err := c.ParamsInt("id")
+	if err != nil || templateID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template ID",
+		})
+	}
+
+	template, err := findUserWorkoutTemplate(h.db.DB, uint(templateID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Template not found or doesn't belong to you",
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	templateToUnit(template, unit)
+
+	return c.JSON(template)
+}
+
+func (h *WorkoutTemplateHandler) CreateWorkoutTemplate(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var templateDto dto.WorkoutTemplateDto
+	if err := c.BodyParser(&templateDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(templateDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	exerciseIDs := templateExerciseIDs(templateDto.Exercises)
+	if err := checkExercisesExist(h.db.DB, exerciseIDs); err != nil {
+		return respondExerciseCheckError(c, err, "Exercises", exerciseIDs)
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	templateDtoToKilograms(templateDto.Exercises, unit)
+
+	template := models.WorkoutTemplate{
+		UserID:    userID,
+		Name:      templateDto.Name,
+		Exercises: templateExercisesFromDto(templateDto.Exercises),
+	}
+
+	if err := h.db.DB.Create(&template).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	completeTemplate, _ := findUserWorkoutTemplate(h.db.DB, template.ID, userID)
+	if completeTemplate != nil {
+		templateToUnit(completeTemplate, unit)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(completeTemplate)
+}
+
+func (h *WorkoutTemplateHandler) UpdateWorkoutTemplate(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	templateID, err := c.ParamsInt("id")
+	if err != nil || templateID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template ID",
+		})
+	}
+
+	var templateDto dto.WorkoutTemplateDto
+	if err := c.BodyParser(&templateDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(templateDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	template, err := findUserWorkoutTemplate(h.db.DB, uint(templateID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Template not found or doesn't belong to you",
+		})
+	}
+
+	exerciseIDs := templateExerciseIDs(templateDto.Exercises)
+	if err := checkExercisesExist(h.db.DB, exerciseIDs); err != nil {
+		return respondExerciseCheckError(c, err, "Exercises", exerciseIDs)
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	templateDtoToKilograms(templateDto.Exercises, unit)
+
+	// Replace the exercise list as a whole, like records replace their sets
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("workout_template_id = ?", template.ID).Delete(&models.WorkoutTemplateExercise{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(template).Update("name", templateDto.Name).Error; err != nil {
+			return err
+		}
+
+		exercises := templateExercisesFromDto(templateDto.Exercises)
+		for i := range exercises {
+			exercises[i].WorkoutTemplateID = template.ID
+		}
+		return tx.Create(&exercises).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update template",
+		})
+	}
+
+	updatedTemplate, _ := findUserWorkoutTemplate(h.db.DB, template.ID, userID)
+	if updatedTemplate != nil {
+		templateToUnit(updatedTemplate, unit)
+	}
+
+	return c.JSON(updatedTemplate)
+}
+
+func (h *WorkoutTemplateHandler) DeleteWorkoutTemplate(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	templateID, err := c.ParamsInt("id")
+	if err != nil || templateID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template ID",
+		})
+	}
+
+	template, err := findUserWorkoutTemplate(h.db.DB, uint(templateID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Template not found or doesn't belong to you",
+		})
+	}
+
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("workout_template_id = ?", template.ID).Delete(&models.WorkoutTemplateExercise{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(template).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete template",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateWorkoutFromTemplate instantiates a workout with one placeholder record
+// per template exercise, pre-filled from the user's last performance of it
+func (h *WorkoutTemplateHandler) CreateWorkoutFromTemplate(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	templateID, err := c.ParamsInt("id")
+	if err != nil || templateID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid template ID",
+		})
+	}
+
+	// The body is optional, it only overrides label and date
+	var fromTemplateDto dto.WorkoutFromTemplateDto
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&fromTemplateDto); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Cannot parse JSON",
+			})
+		}
+	}
+
+	if errors := utils.ValidateStruct(fromTemplateDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	template, err := findUserWorkoutTemplate(h.db.DB, uint(templateID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Template not found or doesn't belong to you",
+		})
+	}
+
+	workout := models.Workout{
+		UserID: userID,
+		Label:  template.Name,
+	}
+
+	if fromTemplateDto.Label != nil {
+		workout.Label = *fromTemplateDto.Label
+	}
+
+	// Set custom date if provided, plain days start at midnight in the user's timezone
+	if fromTemplateDto.Date != nil && *fromTemplateDto.Date != "" {
+		loc, err := userLocation(h.db.DB, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		parsed, dateError := utils.ParseLogDate("Date", *fromTemplateDto.Date, loc, time.Now())
+		if dateError != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Validation failed",
+				"details": []utils.ValidationError{*dateError},
+			})
+		}
+		workout.Date = &parsed
+	}
+
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&workout).Error; err != nil {
+			return err
+		}
+
+		for position, templateExercise := range template.Exercises {
+			lastSets, err := lastPerformedSets(tx, userID, templateExercise.ExerciseID)
+			if err != nil {
+				return err
+			}
+
+			record := models.Record{
+				ExerciseID:  templateExercise.ExerciseID,
+				UserID:      userID,
+				Date:        workout.Date,
+				WorkoutID:   &workout.ID,
+				Position:    position,
+				Placeholder: true,
+				Sets:        prefillSets(templateExercise, lastSets),
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	detail, err := NewWorkoutHandler(h.db).loadWorkoutDetail(workout.ID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(detail)
+}
+
+func templateExerciseIDs(exerciseDtos []dto.WorkoutTemplateExerciseDto) []uint {
+	ids := make([]uint, len(exerciseDtos))
+	for i, exerciseDto := range exerciseDtos {
+		ids[i] = exerciseDto.ExerciseID
+	}
+	return ids
+}
+
+func templateExercisesFromDto(exerciseDtos []dto.WorkoutTemplateExerciseDto) []models.WorkoutTemplateExercise {
+	exercises := make([]models.WorkoutTemplateExercise, len(exerciseDtos))
+	for i, exerciseDto := range exerciseDtos {
+		exercises[i] = models.WorkoutTemplateExercise{
+			ExerciseID:   exerciseDto.ExerciseID,
+			Position:     i,
+			TargetSets:   exerciseDto.TargetSets,
+			TargetReps:   exerciseDto.TargetReps,
+			TargetWeight: exerciseDto.TargetWeight,
+		}
+	}
+	return exercises
+}
+
+// prefillSets builds the template's target number of sets, taking reps and
+// weight from the last performance when there is one and the targets otherwise
+func prefillSets(templateExercise models.WorkoutTemplateExercise, lastSets []models.Set) []models.Set {
+	sets := make([]models.Set, templateExercise.TargetSets)
+	for i := range sets {
+		sets[i].Reps = templateExercise.TargetReps
+		if templateExercise.TargetWeight != nil {
+			sets[i].Weight = *templateExercise.TargetWeight
+		}
+
+		if len(lastSets) > 0 {
+			last := lastSets[min(i, len(lastSets)-1)]
+			sets[i].Reps = last.Reps
+			sets[i].Weight = last.Weight
+		}
+	}
+	return sets
+}
+
+// lastPerformedSets returns the sets of the user's most recent real record of an exercise
+func lastPerformedSets(db *gorm.DB, userID uint, exerciseID uint) ([]models.Set, error) {
+	var record models.Record
+	result := db.
+		Preload("Sets", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		Where("user_id = ? AND exercise_id = ? AND placeholder = ?", userID, exerciseID, false).
+		Order("COALESCE(date, created_at) DESC, created_at DESC").
+		Limit(1).
+		Find(&record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return record.Sets, nil
+}
+
+func findUserWorkoutTemplate(db *gorm.DB, templateID uint, userID uint) (*models.WorkoutTemplate, error) {
+	var template models.WorkoutTemplate
+	result := db.
+		Preload("Exercises", orderByPosition).
+		Preload("Exercises.Exercise").
+		Where("id = ? AND user_id = ?", templateID, userID).
+		First(&template)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &template, nil
+}
+
+func orderByPosition(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
+}
@@ -1,14 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/nagy135/fitness-tracker/database"
 	"github.com/nagy135/fitness-tracker/dto"
 	"github.com/nagy135/fitness-tracker/internal/auth"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/internal/units"
 	"github.com/nagy135/fitness-tracker/models"
 	"github.com/nagy135/fitness-tracker/utils"
+	"gorm.io/gorm"
 )
 
 type WorkoutHandler struct {
@@ -42,6 +46,32 @@ func (h *WorkoutHandler) GetWorkouts(c *fiber.Ctx) error {
 	})
 }
 
+func (h *WorkoutHandler) GetWorkout(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	workoutID, err := c.ParamsInt("id")
+	if err != nil || workoutID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workout ID",
+		})
+	}
+
+	detail, err := h.loadWorkoutDetail(uint(workoutID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workout not found or doesn't belong to you",
+		})
+	}
+
+	setETag(c, detail.Version)
+	return c.JSON(detail)
+}
+
 func (h *WorkoutHandler) CreateWorkout(c *fiber.Ctx) error {
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
@@ -69,11 +99,23 @@ func (h *WorkoutHandler) CreateWorkout(c *fiber.Ctx) error {
 		Label:  workoutDto.Label,
 	}
 
-	// Set custom date if provided
+	// Set custom date if provided, plain days start at midnight in the user's timezone
 	if workoutDto.Date != nil && *workoutDto.Date != "" {
-		if parsedDate, err := time.Parse("2006-01-02", *workoutDto.Date); err == nil {
-			workout.Date = &parsedDate
+		loc, err := userLocation(h.db.DB, userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+
+		parsed, dateError := utils.ParseLogDate("Date", *workoutDto.Date, loc, time.Now())
+		if dateError != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Validation failed",
+				"details": []utils.ValidationError{*dateError},
+			})
 		}
+		workout.Date = &parsed
 	}
 
 	result := h.db.DB.Create(&workout)
@@ -83,10 +125,12 @@ func (h *WorkoutHandler) CreateWorkout(c *fiber.Ctx) error {
 		})
 	}
 
+	setETag(c, workout.Version)
 	return c.Status(fiber.StatusCreated).JSON(workout)
 }
 
-func (h *WorkoutHandler) GetWorkoutStats(c *fiber.Ctx) error {
+// PatchWorkout changes the label or date of a workout, absent fields are kept
+func (h *WorkoutHandler) PatchWorkout(c *fiber.Ctx) error {
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -94,120 +138,188 @@ func (h *WorkoutHandler) GetWorkoutStats(c *fiber.Ctx) error {
 		})
 	}
 
-	type WorkoutStats struct {
-		Date        string  `json:"date"`
-		TotalWeight float32 `json:"totalWeight"`
-		WorkoutName string  `json:"workoutName"`
+	workoutID, err := c.ParamsInt("id")
+	if err != nil || workoutID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workout ID",
+		})
 	}
 
-	// Get all records with their sets and exercise info for the user
-	var records []models.Record
-	result := h.db.DB.Preload("Sets").Preload("Exercise").Where("user_id = ?", userID).Find(&records)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": result.Error.Error(),
+	var patchDto dto.PatchWorkoutDto
+	if err := c.BodyParser(&patchDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
 		})
 	}
 
-	// Get all workouts for the user
-	var workouts []models.Workout
-	result = h.db.DB.Where("user_id = ?", userID).Find(&workouts)
-	if result.Error != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": result.Error.Error(),
+	if errors := utils.ValidateStruct(patchDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
 		})
 	}
 
-	// Calculate daily weights from records
-	dailyWeights := make(map[string]float32)
-	for _, record := range records {
-		// Use record date if available, otherwise use created_at
-		var date time.Time
-		if record.Date != nil {
-			date = *record.Date
-		} else {
-			date = record.CreatedAt
+	workout, err := findUserWorkout(h.db.DB, uint(workoutID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workout not found or doesn't belong to you",
+		})
+	}
+
+	if ok, err := checkIfMatch(c, workout.Version); !ok {
+		return err
+	}
+
+	// Only the given columns are written, whatever else changed since the
+	// workout was loaded stays
+	var columns []string
+	if patchDto.Label != nil {
+		workout.Label = *patchDto.Label
+		columns = append(columns, "label")
+	}
+
+	// null or an empty string clears the date
+	if patchDto.Date.Set {
+		columns = append(columns, "date")
+		workout.Date = nil
+		if patchDto.Date.Value != nil && *patchDto.Date.Value != "" {
+			loc, err := userLocation(h.db.DB, userID)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+
+			parsed, dateError := utils.ParseLogDate("Date", *patchDto.Date.Value, loc, time.Now())
+			if dateError != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error":   "Validation failed",
+					"details": []utils.ValidationError{*dateError},
+				})
+			}
+			workout.Date = &parsed
 		}
+	}
 
-		dateStr := date.Format("2006-01-02")
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := claimVersion(tx, &models.Workout{}, workout.ID, workout.Version); err != nil {
+			return err
+		}
+		workout.Version++
 
-		// Calculate total weight for this record (sum of all sets)
-		var recordWeight float32
-		for _, set := range record.Sets {
-			recordWeight += set.Weight * float32(set.Reps)
+		if len(columns) == 0 {
+			return nil
 		}
+		return tx.Model(workout).Select(columns).Updates(workout).Error
+	})
+	if errors.Is(err, errVersionConflict) {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-		// Apply exercise weight multiplier
-		recordWeight *= record.Exercise.TotalWeightMultiplier
+	setETag(c, workout.Version)
+	return c.JSON(workout)
+}
 
-		// Add to daily total
-		dailyWeights[dateStr] += recordWeight
+func (h *WorkoutHandler) GetWorkoutStats(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	// Create workout name lookup by date
-	workoutsByDate := make(map[string]string)
-	for _, workout := range workouts {
-		// Use workout date if available, otherwise use created_at
-		var date time.Time
-		if workout.Date != nil {
-			date = *workout.Date
-		} else {
-			date = workout.CreatedAt
-		}
+	type WorkoutStats struct {
+		Date        string          `json:"date"`
+		WorkoutID   *uint           `json:"workoutId,omitempty"`
+		TotalWeight decimal.Decimal `json:"totalWeight"`
+		WorkoutName string          `json:"workoutName"`
 
-		dateStr := date.Format("2006-01-02")
+		// Only present on live sessions
+		DurationMinutes float64           `json:"durationMinutes,omitempty"`
+		Density         float64           `json:"density,omitempty"`
+		Rest            *dto.RestStatsDto `json:"rest,omitempty"`
+	}
 
-		// If multiple workouts on same day, concatenate names
-		if existing, exists := workoutsByDate[dateStr]; exists {
-			workoutsByDate[dateStr] = existing + " + " + workout.Label
-		} else {
-			workoutsByDate[dateStr] = workout.Label
-		}
+	var rangeQuery dto.StatsRangeQueryDto
+	if err := c.QueryParser(&rangeQuery); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse query",
+		})
+	}
+
+	if errors := utils.ValidateStruct(rangeQuery); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	loc, err := userLocation(h.db.DB, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	// Combine data and create stats
-	var stats []WorkoutStats
+	// Days are those of the user's timezone
+	statsRange := newStatsRange(rangeQuery.From, rangeQuery.To, loc)
 
-	// Get all unique dates from both maps
-	dateSet := make(map[string]bool)
-	for date := range dailyWeights {
-		dateSet[date] = true
+	// Every workout is its own entry, records outside of workouts are
+	// grouped by day. Totals and session metrics are aggregated in SQL.
+	workoutStats, err := queryWorkoutStats(h.db.DB, userID, statsRange)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
-	for date := range workoutsByDate {
-		dateSet[date] = true
+
+	rests, err := queryWorkoutRests(h.db.DB, userID, statsRange)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	// Create stats for each date
-	for date := range dateSet {
+	stats := make([]WorkoutStats, 0, len(workoutStats))
+	for _, workoutStat := range workoutStats {
 		stat := WorkoutStats{
-			Date:        date,
-			TotalWeight: dailyWeights[date], // defaults to 0 if not found
-			WorkoutName: workoutsByDate[date],
+			Date:        workoutStat.Day,
+			WorkoutID:   workoutStat.WorkoutID,
+			TotalWeight: units.FromKilograms(workoutStat.Volume, unit),
+			WorkoutName: workoutStatName(workoutStat),
 		}
 
-		// If no workout name, use default
-		if stat.WorkoutName == "" {
-			stat.WorkoutName = "Workout"
+		if workoutStat.Minutes > 0 {
+			stat.DurationMinutes = workoutStat.Minutes
+			stat.Density = decimal.RoundRatio(stat.TotalWeight.Float64() / workoutStat.Minutes)
+			stat.Rest = rests[*workoutStat.WorkoutID]
 		}
 
 		stats = append(stats, stat)
 	}
 
-	// Sort by date descending
-	for i := 0; i < len(stats); i++ {
-		for j := i + 1; j < len(stats); j++ {
-			if stats[i].Date < stats[j].Date {
-				stats[i], stats[j] = stats[j], stats[i]
-			}
-		}
-	}
-
 	return c.JSON(fiber.Map{
 		"stats": stats,
 		"count": len(stats),
 	})
 }
 
+// GetWorkoutStatsByDate returns the workouts of a day with their exercises and
+// sets, records outside of workouts make up one more entry
 func (h *WorkoutHandler) GetWorkoutStatsByDate(c *fiber.Ctx) error {
 	userID, err := auth.GetUserIDFromToken(c)
 	if err != nil {
@@ -224,140 +336,411 @@ func (h *WorkoutHandler) GetWorkoutStatsByDate(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validate the date format
-	_, err = time.Parse("2006-01-02", dateParam)
+	loc, err := userLocation(h.db.DB, userID)
 	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Validate the date format, the day is the user's local day
+	if _, err := time.ParseInLocation("2006-01-02", dateParam, loc); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid date format. Use YYYY-MM-DD",
 		})
 	}
 
 	type SetDetail struct {
-		Reps   int     `json:"reps"`
-		Weight float32 `json:"weight"`
+		Reps   int             `json:"reps"`
+		Weight decimal.Decimal `json:"weight"`
 	}
 
 	type ExerciseStats struct {
-		ExerciseName string      `json:"exerciseName"`
-		TotalWeight  float32     `json:"totalWeight"`
-		SetDetails   []SetDetail `json:"setDetails"`
+		ExerciseName string          `json:"exerciseName"`
+		TotalWeight  decimal.Decimal `json:"totalWeight"`
+		SetDetails   []SetDetail     `json:"setDetails"`
+
+		// Superset, giant set or circuit the exercise was performed in
+		GroupType  models.WorkoutGroupType `json:"groupType,omitempty"`
+		GroupLabel string                  `json:"groupLabel,omitempty"`
 	}
 
-	type DayStats struct {
-		Date            string          `json:"date"`
-		TotalWeight     float32         `json:"totalWeight"`
+	type WorkoutDayStats struct {
+		WorkoutID       *uint           `json:"workoutId,omitempty"`
 		WorkoutName     string          `json:"workoutName"`
+		TotalWeight     decimal.Decimal `json:"totalWeight"`
 		ExerciseDetails []ExerciseStats `json:"exerciseDetails"`
+
+		// Only present on live sessions
+		DurationMinutes float64           `json:"durationMinutes,omitempty"`
+		Density         float64           `json:"density,omitempty"`
+		Rest            *dto.RestStatsDto `json:"rest,omitempty"`
 	}
 
-	// Get all records for this specific date with their sets and exercise info
-	var records []models.Record
-	result := h.db.DB.Preload("Sets").Preload("Exercise").Where("user_id = ?", userID).Find(&records)
-	if result.Error != nil {
+	type DayStats struct {
+		Date        string            `json:"date"`
+		TotalWeight decimal.Decimal   `json:"totalWeight"`
+		Workouts    []WorkoutDayStats `json:"workouts"`
+	}
+
+	unit, err := userUnit(h.db.DB, userID)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": result.Error.Error(),
+			"error": err.Error(),
 		})
 	}
 
-	// Get workout for this date
-	var workouts []models.Workout
-	result = h.db.DB.Where("user_id = ?", userID).Find(&workouts)
-	if result.Error != nil {
+	// Workouts, exercises and live session metrics of the day are aggregated in SQL
+	statsRange := newStatsRange(dateParam, dateParam, loc)
+
+	workoutStats, err := queryWorkoutStats(h.db.DB, userID, statsRange)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": result.Error.Error(),
+			"error": err.Error(),
 		})
 	}
 
-	// Filter records for the specific date and calculate exercise totals
-	exerciseWeights := make(map[string]float32)
-	exerciseSets := make(map[string][]SetDetail)
-	exerciseOrder := make(map[string]time.Time) // Track order of exercises
-	var totalWeight float32
+	rests, err := queryWorkoutRests(h.db.DB, userID, statsRange)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-	for _, record := range records {
-		// Use record date if available, otherwise use created_at
-		var recordDate time.Time
-		if record.Date != nil {
-			recordDate = *record.Date
-		} else {
-			recordDate = record.CreatedAt
-		}
+	exercises, err := queryDayExercises(h.db.DB, userID, statsRange)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-		// Check if this record is from the target date
-		if recordDate.Format("2006-01-02") == dateParam {
-			// Calculate total weight for this record and collect set details
-			var recordWeight float32
-			for _, set := range record.Sets {
-				recordWeight += set.Weight * float32(set.Reps)
-				// Add set details for this exercise
-				exerciseSets[record.Exercise.Name] = append(exerciseSets[record.Exercise.Name], SetDetail{
-					Reps:   set.Reps,
-					Weight: set.Weight,
-				})
-			}
+	sets, err := queryDaySets(h.db.DB, userID, statsRange)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-			// Apply exercise weight multiplier
-			recordWeight *= record.Exercise.TotalWeightMultiplier
+	// Exercises and sets are keyed by workout, 0 for records outside of workouts
+	type exerciseKey struct {
+		workoutID uint
+		name      string
+	}
+	exerciseSets := make(map[exerciseKey][]SetDetail, len(exercises))
+	for _, set := range sets {
+		key := exerciseKey{workoutStatKey(set.WorkoutID), set.ExerciseName}
+		exerciseSets[key] = append(exerciseSets[key], SetDetail{
+			Reps:   set.Reps,
+			Weight: units.FromKilograms(set.Weight, unit),
+		})
+	}
 
-			// Add to exercise total
-			exerciseWeights[record.Exercise.Name] += recordWeight
-			totalWeight += recordWeight
+	exerciseDetails := make(map[uint][]ExerciseStats)
+	for _, exercise := range exercises {
+		key := exerciseKey{workoutStatKey(exercise.WorkoutID), exercise.ExerciseName}
+		details := ExerciseStats{
+			ExerciseName: exercise.ExerciseName,
+			TotalWeight:  units.FromKilograms(exercise.TotalWeight, unit),
+			SetDetails:   exerciseSets[key],
+		}
+		if exercise.GroupType != nil {
+			details.GroupType = models.WorkoutGroupType(*exercise.GroupType)
+		}
+		if exercise.GroupLabel != nil {
+			details.GroupLabel = *exercise.GroupLabel
+		}
+		exerciseDetails[key.workoutID] = append(exerciseDetails[key.workoutID], details)
+	}
 
-			// Track the first occurrence of this exercise (earliest record time)
-			if existingTime, exists := exerciseOrder[record.Exercise.Name]; !exists || record.CreatedAt.Before(existingTime) {
-				exerciseOrder[record.Exercise.Name] = record.CreatedAt
-			}
+	dayStats := DayStats{
+		Date:     dateParam,
+		Workouts: make([]WorkoutDayStats, 0, len(workoutStats)),
+	}
+
+	// Earliest workout of the day first
+	var dayVolume decimal.Decimal
+	for i := len(workoutStats) - 1; i >= 0; i-- {
+		workoutStat := workoutStats[i]
+		stats := WorkoutDayStats{
+			WorkoutID:       workoutStat.WorkoutID,
+			WorkoutName:     workoutStatName(workoutStat),
+			TotalWeight:     units.FromKilograms(workoutStat.Volume, unit),
+			ExerciseDetails: exerciseDetails[workoutStatKey(workoutStat.WorkoutID)],
 		}
+
+		if workoutStat.Minutes > 0 {
+			stats.DurationMinutes = workoutStat.Minutes
+			stats.Density = decimal.RoundRatio(stats.TotalWeight.Float64() / workoutStat.Minutes)
+			stats.Rest = rests[*workoutStat.WorkoutID]
+		}
+
+		dayVolume += workoutStat.Volume
+		dayStats.Workouts = append(dayStats.Workouts, stats)
+	}
+	dayStats.TotalWeight = units.FromKilograms(dayVolume, unit)
+
+	return c.JSON(dayStats)
+}
+
+// workoutStatName is the label of a workout in stats, "Workout" for unnamed
+// ones and records outside of workouts
+func workoutStatName(workoutStat workoutStat) string {
+	if workoutStat.Name == "" {
+		return "Workout"
+	}
+	return workoutStat.Name
+}
+
+// workoutStatKey keys stats by workout, records outside of workouts get 0
+func workoutStatKey(workoutID *uint) uint {
+	if workoutID == nil {
+		return 0
+	}
+	return *workoutID
+}
+
+func (h *WorkoutHandler) AddWorkoutRecord(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	workoutID, err := c.ParamsInt("id")
+	if err != nil || workoutID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workout ID",
+		})
 	}
 
-	// Find workout name for this date
-	var workoutName string
-	for _, workout := range workouts {
-		var workoutDate time.Time
-		if workout.Date != nil {
-			workoutDate = *workout.Date
-		} else {
-			workoutDate = workout.CreatedAt
+	var addRecordDto dto.AddWorkoutRecordDto
+	if err := c.BodyParser(&addRecordDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(addRecordDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	workout, err := findUserWorkout(h.db.DB, uint(workoutID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workout not found or doesn't belong to you",
+		})
+	}
+
+	var record models.Record
+	result := h.db.DB.Where("id = ? AND user_id = ?", addRecordDto.RecordID, userID).First(&record)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Record not found or doesn't belong to you",
+		})
+	}
+
+	if record.WorkoutID != nil && *record.WorkoutID == workout.ID {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Record already belongs to this workout",
+		})
+	}
+
+	position, err := nextWorkoutPosition(h.db.DB, workout.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	previousWorkoutID := record.WorkoutID
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&record).Updates(map[string]interface{}{
+			"workout_id":       workout.ID,
+			"workout_group_id": nil,
+			"position":         position,
+			"version":          bumpVersion,
+		}).Error
+		if err != nil {
+			return err
 		}
 
-		if workoutDate.Format("2006-01-02") == dateParam {
-			if workoutName == "" {
-				workoutName = workout.Label
-			} else {
-				workoutName = workoutName + " + " + workout.Label
-			}
+		if previousWorkoutID != nil {
+			return pruneWorkoutGroups(tx, *previousWorkoutID)
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var completeRecord models.Record
+	h.db.DB.Preload("Exercise").Preload("Sets").First(&completeRecord, record.ID)
+
+	return c.JSON(completeRecord)
+}
+
+func (h *WorkoutHandler) RemoveWorkoutRecord(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	workoutID, err := c.ParamsInt("id")
+	if err != nil || workoutID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workout ID",
+		})
+	}
+
+	recordID, err := c.ParamsInt("recordId")
+	if err != nil || recordID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid record ID",
+		})
+	}
+
+	// Detaching keeps the record itself, it only loses its workout and group
+	var rowsAffected int64
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Record{}).
+			Where("id = ? AND workout_id = ? AND user_id = ?", recordID, workoutID, userID).
+			Updates(map[string]interface{}{
+				"workout_id":       nil,
+				"workout_group_id": nil,
+				"position":         0,
+				"version":          bumpVersion,
+			})
+		if result.Error != nil {
+			return result.Error
 		}
+
+		rowsAffected = result.RowsAffected
+		return pruneWorkoutGroups(tx, uint(workoutID))
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	if workoutName == "" {
-		workoutName = "Workout"
+	if rowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Record not found in this workout",
+		})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *WorkoutHandler) ReorderWorkoutRecords(c *fiber.Ctx) error {
+	userID, err := auth.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	// Convert exercise weights map to slice
-	var exerciseDetails []ExerciseStats
-	for exerciseName, weight := range exerciseWeights {
-		exerciseDetails = append(exerciseDetails, ExerciseStats{
-			ExerciseName: exerciseName,
-			TotalWeight:  weight,
-			SetDetails:   exerciseSets[exerciseName],
+	workoutID, err := c.ParamsInt("id")
+	if err != nil || workoutID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid workout ID",
 		})
 	}
 
-	// Sort exercise details by the order they were recorded (creation time)
-	for i := 0; i < len(exerciseDetails); i++ {
-		for j := i + 1; j < len(exerciseDetails); j++ {
-			if exerciseOrder[exerciseDetails[i].ExerciseName].After(exerciseOrder[exerciseDetails[j].ExerciseName]) {
-				exerciseDetails[i], exerciseDetails[j] = exerciseDetails[j], exerciseDetails[i]
+	var reorderDto dto.ReorderWorkoutRecordsDto
+	if err := c.BodyParser(&reorderDto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON",
+		})
+	}
+
+	if errors := utils.ValidateStruct(reorderDto); len(errors) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Validation failed",
+			"details": errors,
+		})
+	}
+
+	workout, err := findUserWorkout(h.db.DB, uint(workoutID), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Workout not found or doesn't belong to you",
+		})
+	}
+
+	// The new order must list every record of the workout exactly once
+	var currentIDs []uint
+	if err := h.db.DB.Model(&models.Record{}).Where("workout_id = ?", workout.ID).Pluck("id", &currentIDs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	current := make(map[uint]bool, len(currentIDs))
+	for _, id := range currentIDs {
+		current[id] = true
+	}
+
+	seen := make(map[uint]bool, len(reorderDto.RecordIDs))
+	for _, id := range reorderDto.RecordIDs {
+		if !current[id] || seen[id] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "recordIds must list every record of the workout exactly once",
+			})
+		}
+		seen[id] = true
+	}
+
+	if len(seen) != len(current) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "recordIds must list every record of the workout exactly once",
+		})
+	}
+
+	err = h.db.DB.Transaction(func(tx *gorm.DB) error {
+		for position, id := range reorderDto.RecordIDs {
+			err := tx.Model(&models.Record{}).Where("id = ?", id).Updates(map[string]interface{}{
+				"position": position,
+				"version":  bumpVersion,
+			}).Error
+			if err != nil {
+				return err
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reorder records",
+		})
 	}
 
-	dayStats := DayStats{
-		Date:            dateParam,
-		TotalWeight:     totalWeight,
-		WorkoutName:     workoutName,
-		ExerciseDetails: exerciseDetails,
+	return h.GetWorkout(c)
+}
+
+// findUserWorkout loads a workout only if it belongs to the given user
+func findUserWorkout(db *gorm.DB, workoutID uint, userID uint) (*models.Workout, error) {
+	var workout models.Workout
+	if err := db.Where("id = ? AND user_id = ?", workoutID, userID).First(&workout).Error; err != nil {
+		return nil, err
 	}
+	return &workout, nil
+}
 
-	return c.JSON(dayStats)
+// nextWorkoutPosition returns the position right after the last record of a workout
+func nextWorkoutPosition(db *gorm.DB, workoutID uint) (int, error) {
+	var position int
+	err := db.Model(&models.Record{}).
+		Where("workout_id = ?", workoutID).
+		Select("COALESCE(MAX(position) + 1, 0)").
+		Scan(&position).Error
+	return position, err
 }
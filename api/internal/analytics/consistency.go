@@ -0,0 +1,89 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/nagy135/fitness-tracker/dto"
+)
+
+// WeekStart returns the Monday of the week t falls in
+func WeekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// WeeklySessions sums daily session counts keyed by YYYY-MM-DD into weeks
+// keyed by their Monday
+func WeeklySessions(daily map[string]int) map[string]int {
+	weekly := make(map[string]int)
+	for day, sessions := range daily {
+		date, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			continue
+		}
+		weekly[WeekStart(date).Format("2006-01-02")] += sessions
+	}
+	return weekly
+}
+
+// Streaks counts consecutive weeks meeting the session target. The week of now
+// is still in progress, so it only extends the current streak once met.
+func Streaks(weekly map[string]int, target int, now time.Time) dto.StreaksDto {
+	var streaks dto.StreaksDto
+	if len(weekly) == 0 {
+		return streaks
+	}
+
+	thisWeek := WeekStart(now)
+	first := thisWeek
+	for week := range weekly {
+		if date, err := time.Parse("2006-01-02", week); err == nil && date.Before(first) {
+			first = date
+		}
+	}
+
+	run := 0
+	for week := first; !week.After(thisWeek); week = week.AddDate(0, 0, 7) {
+		if weekly[week.Format("2006-01-02")] >= target {
+			run++
+			streaks.Longest = max(streaks.Longest, run)
+		} else if week.Before(thisWeek) {
+			run = 0
+		}
+	}
+	streaks.Current = run
+	return streaks
+}
+
+// Adherence reports which weeks starting in the year met the target, up to the
+// week of now. The running week counts towards elapsed weeks only once met.
+func Adherence(weekly map[string]int, target int, year int, now time.Time) dto.AdherenceDto {
+	adherence := dto.AdherenceDto{WeeklyTarget: target, Weeks: []dto.WeekAdherenceDto{}}
+	thisWeek := WeekStart(now)
+
+	week := WeekStart(time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if week.Year() < year {
+		week = week.AddDate(0, 0, 7)
+	}
+	for ; week.Year() == year && !week.After(thisWeek); week = week.AddDate(0, 0, 7) {
+		sessions := weekly[week.Format("2006-01-02")]
+		met := sessions >= target
+		adherence.Weeks = append(adherence.Weeks, dto.WeekAdherenceDto{
+			Start:    week.Format("2006-01-02"),
+			Sessions: sessions,
+			Met:      met,
+		})
+
+		if met {
+			adherence.WeeksMet++
+		}
+		if met || week.Before(thisWeek) {
+			adherence.WeeksElapsed++
+		}
+	}
+
+	if adherence.WeeksElapsed > 0 {
+		adherence.Percent = float64(adherence.WeeksMet) / float64(adherence.WeeksElapsed) * 100
+	}
+	return adherence
+}
@@ -0,0 +1,81 @@
+package analytics
+
+import (
+	"math"
+	"time"
+)
+
+// GoalPoint is a value of a goal's metric on a day
+type GoalPoint struct {
+	Date  time.Time
+	Value float64
+}
+
+// PercentComplete is how far current got from start towards target, clamped
+// to 0-100. Works for targets below the start, like losing bodyweight.
+func PercentComplete(start float64, current float64, target float64) float64 {
+	if target == start {
+		if GoalReached(start, current, target) {
+			return 100
+		}
+		return 0
+	}
+	percent := (current - start) / (target - start) * 100
+	return math.Max(0, math.Min(100, percent))
+}
+
+// maxProjectionDays is how far ahead a projection is still given, a trend
+// that needs longer is too flat to mean anything
+const maxProjectionDays = 100 * 365
+
+// ProjectCompletion fits a line through the points and returns the day it
+// reaches the target, or nil when it heads away from it, would take over a
+// century or there are too few points to tell
+func ProjectCompletion(points []GoalPoint, start float64, target float64) *time.Time {
+	if len(points) < 2 {
+		return nil
+	}
+
+	origin := points[0].Date
+	var sumX, sumY, sumXY, sumXX float64
+	for _, point := range points {
+		x := point.Date.Sub(origin).Hours() / 24
+		sumX += x
+		sumY += point.Value
+		sumXY += x * point.Value
+		sumXX += x * x
+	}
+
+	n := float64(len(points))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return nil
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - slope*sumX) / n
+
+	// The trend has to move the same way as start to target
+	if slope == 0 || (target > start) != (slope > 0) {
+		return nil
+	}
+
+	// A trend already past the target projects to the last point
+	days := math.Max((target-intercept)/slope, 0)
+	if days > maxProjectionDays {
+		return nil
+	}
+	last := points[len(points)-1].Date
+	projected := origin.Add(time.Duration(math.Ceil(days)) * 24 * time.Hour)
+	if projected.Before(last) {
+		projected = last
+	}
+	return &projected
+}
+
+// GoalReached reports whether current is at or past target coming from start
+func GoalReached(start float64, current float64, target float64) bool {
+	if target < start {
+		return current <= target
+	}
+	return current >= target
+}
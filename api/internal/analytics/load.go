@@ -0,0 +1,117 @@
+package analytics
+
+import (
+	"math"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+)
+
+const (
+	// AcuteDays and ChronicDays are the rolling windows of the workload ratio
+	AcuteDays   = 7
+	ChronicDays = 28
+
+	// Default warning thresholds, an ACWR above 1.5 is commonly linked to
+	// injury risk and a monotony above 2 to too little variation
+	DefaultACWRThreshold     = 1.5
+	DefaultMonotonyThreshold = 2.0
+)
+
+// Thresholds above which a load metric produces a warning, zero disables it
+type Thresholds struct {
+	ACWR     float64
+	Monotony float64
+	Strain   float64
+}
+
+// ComputeLoad computes rolling load metrics for every day in [from, to] out of
+// daily volumes keyed by YYYY-MM-DD. Volumes must cover ChronicDays-1 days
+// before from for the chronic load of the first days to be complete.
+func ComputeLoad(volumes map[string]decimal.Decimal, from time.Time, to time.Time, thresholds Thresholds) []dto.LoadDayDto {
+	var days []dto.LoadDayDto
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		acute := window(volumes, day, AcuteDays)
+		chronic := window(volumes, day, ChronicDays)
+		acuteMean := mean(acute)
+		chronicMean := mean(chronic)
+
+		var acwr, monotony float64
+		if chronicMean > 0 {
+			acwr = acuteMean / chronicMean
+		}
+
+		// Foster's monotony is the weekly mean over its standard deviation,
+		// strain the weekly load scaled by it
+		if deviation := stddev(acute); deviation > 0 {
+			monotony = acuteMean / deviation
+		}
+
+		load := dto.LoadDayDto{
+			Date:     day.Format("2006-01-02"),
+			Volume:   volumes[day.Format("2006-01-02")],
+			Acute:    decimal.FromFloat(acuteMean),
+			Chronic:  decimal.FromFloat(chronicMean),
+			ACWR:     decimal.RoundRatio(acwr),
+			Monotony: decimal.RoundRatio(monotony),
+			Strain:   decimal.FromFloat(sum(acute) * monotony),
+		}
+		load.Warnings = warnings(load, thresholds)
+		days = append(days, load)
+	}
+	return days
+}
+
+// window returns the volumes of the given number of days ending with day
+func window(volumes map[string]decimal.Decimal, day time.Time, days int) []float64 {
+	values := make([]float64, days)
+	for i := range values {
+		values[i] = volumes[day.AddDate(0, 0, -i).Format("2006-01-02")].Float64()
+	}
+	return values
+}
+
+func warnings(load dto.LoadDayDto, thresholds Thresholds) []dto.LoadWarningDto {
+	var warnings []dto.LoadWarningDto
+	check := func(metric string, value float64, threshold float64) {
+		if threshold > 0 && value > threshold {
+			warnings = append(warnings, dto.LoadWarningDto{
+				Metric:    metric,
+				Value:     value,
+				Threshold: threshold,
+			})
+		}
+	}
+	check("acwr", load.ACWR, thresholds.ACWR)
+	check("monotony", load.Monotony, thresholds.Monotony)
+	check("strain", load.Strain.Float64(), thresholds.Strain)
+	return warnings
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return sum(values) / float64(len(values))
+}
+
+func stddev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	average := mean(values)
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - average) * (value - average)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
@@ -30,6 +30,9 @@ type ServerConfig struct {
 	Port         string
 	BaseURL      string
 	AllowOrigins string
+
+	// How long responses are kept for replay to requests with an Idempotency-Key
+	IdempotencyWindow time.Duration
 }
 
 // LoadConfig loads configuration from environment variables
@@ -52,6 +55,7 @@ func LoadConfig() *Config {
 			Port:         getEnv("SERVER_PORT", "8080"),
 			BaseURL:      getEnv("SERVER_BASE_URL", "http://localhost:8080"),
 			AllowOrigins: getEnv("SERVER_ALLOW_ORIGINS", "http://localhost:3004"),
+			IdempotencyWindow: getEnvDuration("SERVER_IDEMPOTENCY_WINDOW", time.Hour*24),
 		},
 	}
 }
@@ -62,3 +66,14 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvDuration reads a duration like "24h" or "90m", falling back to the
+// default when unset or invalid
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,192 @@
+package decimal
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Places is the number of decimal places a Decimal keeps
+const Places = 3
+
+const scale = 1000
+
+// Decimal is a fixed-point number with three decimal places, used for weights
+// and the totals computed from them so sums don't drift like floats do. It is
+// stored as NUMERIC and serialized as a plain JSON number.
+//
+// Every operation that can produce more places rounds half away from zero,
+// the same way Postgres rounds NUMERIC.
+type Decimal int64
+
+// FromInt returns n as a Decimal
+func FromInt(n int64) Decimal {
+	return Decimal(n * scale)
+}
+
+// FromFloat rounds f to three places
+func FromFloat(f float64) Decimal {
+	return Decimal(math.Round(f * scale))
+}
+
+// Parse reads a decimal string such as "102.5" exactly, rounding any places
+// beyond the third
+func Parse(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty decimal")
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid decimal %q", s)
+		}
+		return FromFloat(f), nil
+	}
+
+	input := s
+	negative := strings.HasPrefix(s, "-")
+	if negative || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	// Only digits around a single point, with at least one digit
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole+fraction == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid decimal %q", input)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	integer, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal %q", input)
+	}
+
+	// Keep one extra digit to round on
+	fraction += strings.Repeat("0", Places+1)
+	digits, err := strconv.ParseInt(fraction[:Places+1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid decimal %q", input)
+	}
+
+	value := integer*scale + (digits+5)/10
+	if negative {
+		value = -value
+	}
+	return Decimal(value), nil
+}
+
+// Float64 converts the decimal for calculations that don't need to be exact
+func (d Decimal) Float64() float64 {
+	return float64(d) / scale
+}
+
+// MulInt multiplies exactly, such as a weight by reps
+func (d Decimal) MulInt(n int) Decimal {
+	return d * Decimal(n)
+}
+
+// MulFloat multiplies by a factor such as a weight multiplier or a unit
+// conversion and rounds the result
+func (d Decimal) MulFloat(f float64) Decimal {
+	return FromFloat(d.Float64() * f)
+}
+
+// DivInt divides and rounds the result, for averages
+func (d Decimal) DivInt(n int) Decimal {
+	if n == 0 {
+		return 0
+	}
+	return FromFloat(float64(d) / float64(n) / scale)
+}
+
+// Round rounds to the given number of places, up to three
+func (d Decimal) Round(places int) Decimal {
+	if places >= Places {
+		return d
+	}
+	step := math.Pow10(Places - places)
+	return Decimal(math.Round(float64(d)/step) * step)
+}
+
+// String formats the decimal without trailing zeros, like 2497.5
+func (d Decimal) String() string {
+	sign := ""
+	value := int64(d)
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	whole := value / scale
+	fraction := value % scale
+	if fraction == 0 {
+		return sign + strconv.FormatInt(whole, 10)
+	}
+
+	digits := strings.TrimRight(fmt.Sprintf("%03d", fraction), "0")
+	return sign + strconv.FormatInt(whole, 10) + "." + digits
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Scan reads a NUMERIC column, which the driver hands over as text
+func (d *Decimal) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*d = 0
+		return nil
+	case string:
+		parsed, err := Parse(v)
+		*d = parsed
+		return err
+	case []byte:
+		parsed, err := Parse(string(v))
+		*d = parsed
+		return err
+	case float64:
+		*d = FromFloat(v)
+		return nil
+	case float32:
+		*d = FromFloat(float64(v))
+		return nil
+	case int64:
+		*d = FromInt(v)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into decimal", value)
+	}
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (Decimal) GormDataType() string {
+	return "numeric(14,3)"
+}
+
+// RoundRatio rounds rates, ratios and percentages derived from decimals to
+// two places for responses
+func RoundRatio(f float64) float64 {
+	return math.Round(f*100) / 100
+}
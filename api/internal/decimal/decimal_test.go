@@ -0,0 +1,226 @@
+package decimal
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Decimal
+	}{
+		{"0", 0},
+		{"102.5", 102500},
+		{"102.50", 102500},
+		{"007.250", 7250},
+		{"0.001", 1},
+		{".5", 500},
+		{"5.", 5000},
+		{"+2", 2000},
+		{" 3 ", 3000},
+		{"-0.5", -500},
+		{"1.0004", 1000},
+		{"1.0005", 1001},
+		{"-1.0005", -1001},
+		{"2.9999", 3000},
+		{"1e2", 100000},
+		{"2.5E-1", 250},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := Parse(test.value)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", test.value, err)
+			}
+			if got != test.want {
+				t.Errorf("Parse(%q) = %d, want %d", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, value := range []string{"", " ", "-", "+", ".", "abc", "1,5", "1.2.3", "--1", "+-1", "1.x", "1 000", "e5"} {
+		t.Run(value, func(t *testing.T) {
+			if got, err := Parse(value); err == nil {
+				t.Errorf("Parse(%q) = %d, want an error", value, got)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		value Decimal
+		want  string
+	}{
+		{0, "0"},
+		{1, "0.001"},
+		{500, "0.5"},
+		{-500, "-0.5"},
+		{100000, "100"},
+		{2497500, "2497.5"},
+		{102058, "102.058"},
+		{-7250, "-7.25"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := test.value.String(); got != test.want {
+				t.Errorf("Decimal(%d).String() = %q, want %q", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Decimal
+		want Decimal
+	}{
+		{"weight times reps is exact", FromFloat(102.5).MulInt(5), FromFloat(512.5)},
+		{"sum of many sets doesn't drift", FromFloat(0.1).MulInt(3) + FromFloat(0.2), FromFloat(0.5)},
+		{"multiplier rounds to three places", FromInt(10).MulFloat(0.12345), 1235},
+		{"negative multiplier rounds away from zero", FromInt(-10).MulFloat(0.12345), -1235},
+		{"average rounds", FromInt(10).DivInt(3), 3333},
+		{"average rounds half away from zero", Decimal(5).DivInt(2), 3},
+		{"average of nothing is zero", FromInt(10).DivInt(0), 0},
+		{"round to two places", Decimal(225005).Round(2), 225010},
+		{"round to one place", Decimal(2250).Round(1), 2300},
+		{"round negative to one place", Decimal(-2250).Round(1), -2300},
+		{"round to whole", Decimal(102499).Round(0), 102000},
+		{"round to three places keeps", Decimal(102499).Round(3), 102499},
+		{"from float rounds", FromFloat(0.0005), 1},
+		{"from int", FromInt(-3), -3000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.got != test.want {
+				t.Errorf("got %s, want %s", test.got, test.want)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want Decimal
+	}{
+		{`102.5`, 102500},
+		{`"102.5"`, 102500},
+		{`0`, 0},
+		{`-0.25`, -250},
+	}
+
+	for _, test := range tests {
+		t.Run(test.json, func(t *testing.T) {
+			var got Decimal
+			if err := json.Unmarshal([]byte(test.json), &got); err != nil {
+				t.Fatalf("Unmarshal(%s) error = %v", test.json, err)
+			}
+			if got != test.want {
+				t.Fatalf("Unmarshal(%s) = %d, want %d", test.json, got, test.want)
+			}
+
+			encoded, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("Marshal(%d) error = %v", got, err)
+			}
+			var decoded Decimal
+			if err := json.Unmarshal(encoded, &decoded); err != nil || decoded != got {
+				t.Errorf("round trip of %d through %s = %d, %v", got, encoded, decoded, err)
+			}
+		})
+	}
+
+	var kept Decimal = 5
+	if err := json.Unmarshal([]byte(`null`), &kept); err != nil || kept != 5 {
+		t.Errorf("Unmarshal(null) = %d, %v, want the value kept", kept, err)
+	}
+
+	var invalid Decimal
+	if err := json.Unmarshal([]byte(`"heavy"`), &invalid); err == nil {
+		t.Error("Unmarshal(\"heavy\") succeeded, want an error")
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  Decimal
+	}{
+		{"numeric text", "102.500", 102500},
+		{"numeric bytes", []byte("-7.250"), -7250},
+		{"float", 102.5, 102500},
+		{"float32", float32(2.5), 2500},
+		{"integer", int64(3), 3000},
+		{"null", nil, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Decimal(99)
+			if err := got.Scan(test.value); err != nil {
+				t.Fatalf("Scan(%v) error = %v", test.value, err)
+			}
+			if got != test.want {
+				t.Errorf("Scan(%v) = %d, want %d", test.value, got, test.want)
+			}
+		})
+	}
+
+	var got Decimal
+	if err := got.Scan(true); err == nil {
+		t.Error("Scan(true) succeeded, want an error")
+	}
+	if err := got.Scan("heavy"); err == nil {
+		t.Error("Scan(\"heavy\") succeeded, want an error")
+	}
+}
+
+func TestValueScanRoundTrip(t *testing.T) {
+	for _, value := range []Decimal{0, 1, -1, 500, 102058, 2497500, -7250, 99999999999} {
+		t.Run(value.String(), func(t *testing.T) {
+			stored, err := value.Value()
+			if err != nil {
+				t.Fatalf("Value() error = %v", err)
+			}
+			if _, ok := stored.(string); !ok {
+				t.Fatalf("Value() = %T, want numeric text", stored)
+			}
+
+			var scanned Decimal
+			if err := scanned.Scan(stored.(driver.Value)); err != nil {
+				t.Fatalf("Scan(%v) error = %v", stored, err)
+			}
+			if scanned != value {
+				t.Errorf("round trip of %d = %d", value, scanned)
+			}
+		})
+	}
+}
+
+func TestRoundRatio(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  float64
+	}{
+		{1.234, 1.23},
+		{1.235, 1.24},
+		{-1.235, -1.24},
+		{0, 0},
+	}
+
+	for _, test := range tests {
+		if got := RoundRatio(test.value); got != test.want {
+			t.Errorf("RoundRatio(%v) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
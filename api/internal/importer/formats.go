@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/internal/units"
+)
+
+// parseStrong reads a Strong export. Rows are sets, a workout is identified
+// by its start and name. Newer exports name the weight unit per row, older
+// ones use the unit set in the app.
+func parseStrong(t table, loc *time.Location, unit units.Unit) (Result, error) {
+	if err := t.require(Strong, "date", "workout name", "exercise name", "set order", "weight", "reps"); err != nil {
+		return Result{}, err
+	}
+
+	builder := newSessionBuilder()
+	for i, row := range t.rows {
+		date := t.value(row, "date")
+		if date == "" {
+			continue
+		}
+
+		start, err := parseTime(date, loc, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02")
+		if err != nil {
+			return Result{}, rowError(i, err)
+		}
+
+		// Warm-up sets are numbered W
+		if strings.EqualFold(t.value(row, "set order"), "W") {
+			builder.skip()
+			continue
+		}
+
+		setUnit := unit
+		if t.has("weight unit") {
+			setUnit = parseUnitName(t.value(row, "weight unit"), unit)
+		}
+
+		set, ok, err := parseSet(t.value(row, "reps"), t.value(row, "weight"), setUnit)
+		if err != nil {
+			return Result{}, rowError(i, err)
+		}
+		if !ok {
+			builder.skip()
+			continue
+		}
+
+		label := t.value(row, "workout name")
+		session := Session{Label: label, Start: start}
+		if duration := strongDuration(t.value(row, "duration"), t.value(row, "workout duration")); duration > 0 {
+			end := start.Add(duration)
+			session.End = &end
+		}
+
+		builder.add(date+"|"+label, session, t.value(row, "exercise name"), set)
+	}
+
+	return builder.result, nil
+}
+
+// strongDuration reads the workout duration Strong writes as "1h 5m" or in
+// seconds, depending on the export version
+func strongDuration(values ...string) time.Duration {
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if duration, err := time.ParseDuration(strings.ReplaceAll(value, " ", "")); err == nil {
+			return duration
+		}
+	}
+	return 0
+}
+
+// parseHevy reads a Hevy export, one row per set with the workout's title
+// and times on every row. The weight column is named after its unit.
+func parseHevy(t table, loc *time.Location) (Result, error) {
+	if err := t.require(Hevy, "title", "start_time", "exercise_title", "set_type", "reps"); err != nil {
+		return Result{}, err
+	}
+
+	weightColumn, unit := "weight_kg", units.Kilograms
+	if t.has("weight_lbs") {
+		weightColumn, unit = "weight_lbs", units.Pounds
+	}
+
+	layouts := []string{"2 Jan 2006, 15:04", "02 Jan 2006, 15:04", "2006-01-02 15:04:05", time.RFC3339}
+
+	builder := newSessionBuilder()
+	for i, row := range t.rows {
+		startTime := t.value(row, "start_time")
+		if startTime == "" {
+			continue
+		}
+
+		start, err := parseTime(startTime, loc, layouts...)
+		if err != nil {
+			return Result{}, rowError(i, err)
+		}
+
+		if strings.EqualFold(t.value(row, "set_type"), "warmup") {
+			builder.skip()
+			continue
+		}
+
+		set, ok, err := parseSet(t.value(row, "reps"), t.value(row, weightColumn), unit)
+		if err != nil {
+			return Result{}, rowError(i, err)
+		}
+		if !ok {
+			builder.skip()
+			continue
+		}
+
+		title := t.value(row, "title")
+		session := Session{Label: title, Start: start}
+		if endTime := t.value(row, "end_time"); endTime != "" {
+			if end, err := parseTime(endTime, loc, layouts...); err == nil {
+				session.End = &end
+			}
+		}
+
+		builder.add(startTime+"|"+title, session, t.value(row, "exercise_title"), set)
+	}
+
+	return builder.result, nil
+}
+
+// parseFitNotes reads a FitNotes export. It only has days, so every day
+// becomes one workout. The weight column is named after its unit.
+func parseFitNotes(t table, loc *time.Location) (Result, error) {
+	if err := t.require(FitNotes, "date", "exercise", "reps"); err != nil {
+		return Result{}, err
+	}
+
+	weightColumn, unit := "", units.Kilograms
+	for column := range t.columns {
+		if strings.HasPrefix(column, "weight") {
+			weightColumn = column
+			if strings.Contains(column, "lb") {
+				unit = units.Pounds
+			}
+		}
+	}
+	if weightColumn == "" {
+		return Result{}, t.require(FitNotes, "weight (kgs)")
+	}
+
+	builder := newSessionBuilder()
+	for i, row := range t.rows {
+		date := t.value(row, "date")
+		if date == "" {
+			continue
+		}
+
+		day, err := parseTime(date, loc, "2006-01-02")
+		if err != nil {
+			return Result{}, rowError(i, err)
+		}
+
+		set, ok, err := parseSet(t.value(row, "reps"), t.value(row, weightColumn), unit)
+		if err != nil {
+			return Result{}, rowError(i, err)
+		}
+		if !ok {
+			builder.skip()
+			continue
+		}
+
+		builder.add(date, Session{Label: "FitNotes", Start: day}, t.value(row, "exercise"), set)
+	}
+
+	return builder.result, nil
+}
+
+// parseUnitName maps the unit names apps write, like "kg" or "lbs"
+func parseUnitName(value string, fallback units.Unit) units.Unit {
+	switch strings.ToLower(value) {
+	case "kg", "kgs":
+		return units.Kilograms
+	case "lb", "lbs":
+		return units.Pounds
+	default:
+		return fallback
+	}
+}
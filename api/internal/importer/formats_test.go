@@ -0,0 +1,260 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/internal/units"
+)
+
+var testLocation = time.FixedZone("CET", 60*60)
+
+func kg(weight float64) decimal.Decimal {
+	return decimal.FromFloat(weight)
+}
+
+func lb(weight float64) decimal.Decimal {
+	return units.ToKilograms(decimal.FromFloat(weight), units.Pounds)
+}
+
+func at(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, testLocation)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		source Source
+		unit   units.Unit
+		csv    string
+		want   Result
+	}{
+		{
+			name:   "strong with weight unit column",
+			source: Strong,
+			unit:   units.Kilograms,
+			csv: `Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Weight Unit,Reps,RPE,Distance,Distance Unit,Seconds,Notes,Workout Notes
+2023-05-01 18:30:00,"Push Day",1h 5m,"Bench Press (Barbell)",W,40,kg,10,,,,0,"",""
+2023-05-01 18:30:00,"Push Day",1h 5m,"Bench Press (Barbell)",1,100,kg,5,8,,,0,"",""
+2023-05-01 18:30:00,"Push Day",1h 5m,"Bench Press (Barbell)",2,225,lbs,3,9,,,0,"",""
+2023-05-01 18:30:00,"Push Day",1h 5m,"Pull Up",1,,kg,8,,,,0,"",""
+2023-05-01 18:30:00,"Push Day",1h 5m,"Plank",1,0,kg,0,,,,60,"",""
+`,
+			want: Result{
+				Sessions: []Session{{
+					Label: "Push Day",
+					Start: at(2023, time.May, 1, 18, 30),
+					End:   ptr(at(2023, time.May, 1, 19, 35)),
+					Entries: []Entry{
+						{Exercise: "Bench Press (Barbell)", Sets: []Set{{Reps: 5, Weight: kg(100)}, {Reps: 3, Weight: lb(225)}}},
+						{Exercise: "Pull Up", Sets: []Set{{Reps: 8}}},
+					},
+				}},
+				SkippedSets: 2,
+			},
+		},
+		{
+			name:   "strong with semicolons, decimal commas and the app's unit",
+			source: Strong,
+			unit:   units.Pounds,
+			csv: `Date;Workout Name;Duration;Exercise Name;Set Order;Weight;Reps;Distance;Seconds;Notes;Workout Notes;RPE
+2023-05-03 07:00:00;Legs;3600;Squat (Barbell);1;202,5;5;0;0;;;
+2023-05-03 07:00:00;Legs;3600;Squat (Barbell);2;202,5;5;0;0;;;
+2023-05-05 07:10:00;Legs;3000;Deadlift (Barbell);1;315;3;0;0;;;
+`,
+			want: Result{
+				Sessions: []Session{
+					{
+						Label:   "Legs",
+						Start:   at(2023, time.May, 3, 7, 0),
+						End:     ptr(at(2023, time.May, 3, 8, 0)),
+						Entries: []Entry{{Exercise: "Squat (Barbell)", Sets: []Set{{Reps: 5, Weight: lb(202.5)}, {Reps: 5, Weight: lb(202.5)}}}},
+					},
+					{
+						Label:   "Legs",
+						Start:   at(2023, time.May, 5, 7, 10),
+						End:     ptr(at(2023, time.May, 5, 8, 0)),
+						Entries: []Entry{{Exercise: "Deadlift (Barbell)", Sets: []Set{{Reps: 3, Weight: lb(315)}}}},
+					},
+				},
+			},
+		},
+		{
+			name:   "hevy in kilograms",
+			source: Hevy,
+			unit:   units.Pounds,
+			csv: `"title","start_time","end_time","description","exercise_title","superset_id","exercise_notes","set_index","set_type","weight_kg","reps","distance_km","duration_seconds","rpe"
+"Upper A","15 Jan 2024, 18:00","15 Jan 2024, 19:10","","Bench Press (Barbell)",,"",0,"warmup",40,10,,,
+"Upper A","15 Jan 2024, 18:00","15 Jan 2024, 19:10","","Bench Press (Barbell)",,"",1,"normal",80,8,,,8
+"Upper A","15 Jan 2024, 18:00","15 Jan 2024, 19:10","","Pull Up",,"",0,"normal",,10,,,
+"Upper A","15 Jan 2024, 18:00","15 Jan 2024, 19:10","","Plank",,"",0,"normal",,,,60,
+`,
+			want: Result{
+				Sessions: []Session{{
+					Label: "Upper A",
+					Start: at(2024, time.January, 15, 18, 0),
+					End:   ptr(at(2024, time.January, 15, 19, 10)),
+					Entries: []Entry{
+						{Exercise: "Bench Press (Barbell)", Sets: []Set{{Reps: 8, Weight: kg(80)}}},
+						{Exercise: "Pull Up", Sets: []Set{{Reps: 10}}},
+					},
+				}},
+				SkippedSets: 2,
+			},
+		},
+		{
+			name:   "hevy in pounds",
+			source: Hevy,
+			unit:   units.Kilograms,
+			csv: `"title","start_time","end_time","description","exercise_title","superset_id","exercise_notes","set_index","set_type","weight_lbs","reps","distance_miles","duration_seconds","rpe"
+"Lower","3 Feb 2024, 09:05","3 Feb 2024, 10:00","","Squat (Barbell)",,"",0,"normal",185,5,,,
+`,
+			want: Result{
+				Sessions: []Session{{
+					Label:   "Lower",
+					Start:   at(2024, time.February, 3, 9, 5),
+					End:     ptr(at(2024, time.February, 3, 10, 0)),
+					Entries: []Entry{{Exercise: "Squat (Barbell)", Sets: []Set{{Reps: 5, Weight: lb(185)}}}},
+				}},
+			},
+		},
+		{
+			name:   "fitnotes in kilograms",
+			source: FitNotes,
+			unit:   units.Pounds,
+			csv: `Date,Exercise,Category,Weight (kgs),Reps,Distance,Distance Unit,Time
+2024-02-03,Flat Barbell Bench Press,Chest,100.0,5,,,
+2024-02-03,Flat Barbell Bench Press,Chest,100.0,5,,,
+2024-02-03,Pull Up,Back,,8,,,
+2024-02-03,Treadmill,Cardio,,,3.0,km,0:20:00
+2024-02-05,Barbell Squat,Legs,140.0,3,,,
+`,
+			want: Result{
+				Sessions: []Session{
+					{
+						Label: "FitNotes",
+						Start: at(2024, time.February, 3, 0, 0),
+						Entries: []Entry{
+							{Exercise: "Flat Barbell Bench Press", Sets: []Set{{Reps: 5, Weight: kg(100)}, {Reps: 5, Weight: kg(100)}}},
+							{Exercise: "Pull Up", Sets: []Set{{Reps: 8}}},
+						},
+					},
+					{
+						Label:   "FitNotes",
+						Start:   at(2024, time.February, 5, 0, 0),
+						Entries: []Entry{{Exercise: "Barbell Squat", Sets: []Set{{Reps: 3, Weight: kg(140)}}}},
+					},
+				},
+				SkippedSets: 1,
+			},
+		},
+		{
+			name:   "fitnotes in pounds with semicolons",
+			source: FitNotes,
+			unit:   units.Kilograms,
+			csv: string(rune(0xfeff)) + "Date;Exercise;Category;Weight (lbs);Reps;Distance;Distance Unit;Time\n" +
+				"2024-03-01;Deadlift;Back;405,0;1;;;\n",
+			want: Result{
+				Sessions: []Session{{
+					Label:   "FitNotes",
+					Start:   at(2024, time.March, 1, 0, 0),
+					Entries: []Entry{{Exercise: "Deadlift", Sets: []Set{{Reps: 1, Weight: lb(405)}}}},
+				}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Parse(test.source, strings.NewReader(test.csv), testLocation, test.unit)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			assertResult(t, got, test.want)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source Source
+		csv    string
+		want   string
+	}{
+		{
+			name:   "hevy export read as strong",
+			source: Strong,
+			csv:    "title,start_time,end_time,exercise_title,set_type,weight_kg,reps\n",
+			want:   `missing column "date", is this a strong export?`,
+		},
+		{
+			name:   "fitnotes without a weight column",
+			source: FitNotes,
+			csv:    "Date,Exercise,Category,Reps\n",
+			want:   `missing column "weight (kgs)", is this a fitnotes export?`,
+		},
+		{
+			name:   "invalid date",
+			source: FitNotes,
+			csv:    "Date,Exercise,Category,Weight (kgs),Reps\n03/02/2024,Deadlift,Back,100,5\n",
+			want:   `line 2: invalid date "03/02/2024"`,
+		},
+		{
+			name:   "invalid weight",
+			source: Hevy,
+			csv:    "title,start_time,exercise_title,set_type,weight_kg,reps\nA,\"1 Mar 2024, 10:00\",Squat,normal,heavy,5\n",
+			want:   `line 2: invalid weight "heavy"`,
+		},
+		{
+			name:   "unknown source",
+			source: "myapp",
+			csv:    "Date\n",
+			want:   `unknown import source "myapp", use strong, hevy or fitnotes`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.source, strings.NewReader(test.csv), testLocation, units.Kilograms)
+			if err == nil || err.Error() != test.want {
+				t.Fatalf("Parse() error = %v, want %q", err, test.want)
+			}
+		})
+	}
+}
+
+// assertResult compares results with times compared as instants
+func assertResult(t *testing.T, got Result, want Result) {
+	t.Helper()
+
+	if got.SkippedSets != want.SkippedSets {
+		t.Errorf("SkippedSets = %d, want %d", got.SkippedSets, want.SkippedSets)
+	}
+	if len(got.Sessions) != len(want.Sessions) {
+		t.Fatalf("got %d sessions, want %d: %+v", len(got.Sessions), len(want.Sessions), got.Sessions)
+	}
+
+	for i := range want.Sessions {
+		got, want := got.Sessions[i], want.Sessions[i]
+		if got.Label != want.Label {
+			t.Errorf("session %d: Label = %q, want %q", i, got.Label, want.Label)
+		}
+		if !got.Start.Equal(want.Start) {
+			t.Errorf("session %d: Start = %v, want %v", i, got.Start, want.Start)
+		}
+		if (got.End == nil) != (want.End == nil) || (got.End != nil && !got.End.Equal(*want.End)) {
+			t.Errorf("session %d: End = %v, want %v", i, got.End, want.End)
+		}
+		if !reflect.DeepEqual(got.Entries, want.Entries) {
+			t.Errorf("session %d: Entries = %+v, want %+v", i, got.Entries, want.Entries)
+		}
+	}
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/internal/units"
+)
+
+// Source is the app a CSV export comes from
+type Source string
+
+const (
+	Strong   Source = "strong"
+	Hevy     Source = "hevy"
+	FitNotes Source = "fitnotes"
+)
+
+// Set is an imported set, its weight already in kilograms
+type Set struct {
+	Reps   int
+	Weight decimal.Decimal
+}
+
+// Entry is one exercise of a session, it becomes a record
+type Entry struct {
+	Exercise string
+	Sets     []Set
+}
+
+// Session is one imported workout
+type Session struct {
+	Label   string
+	Start   time.Time
+	End     *time.Time
+	Entries []Entry
+}
+
+// Result is what an export contained. Warm-up sets and sets without reps,
+// like cardio or timed holds, have no place in records and are only counted.
+type Result struct {
+	Sessions    []Session
+	SkippedSets int
+}
+
+// Parse reads the CSV export of source. Times without an offset are read in
+// loc, weights of exports that don't name their unit are taken to be in unit.
+func Parse(source Source, r io.Reader, loc *time.Location, unit units.Unit) (Result, error) {
+	table, err := readTable(r)
+	if err != nil {
+		return Result{}, err
+	}
+
+	switch source {
+	case Strong:
+		return parseStrong(table, loc, unit)
+	case Hevy:
+		return parseHevy(table, loc)
+	case FitNotes:
+		return parseFitNotes(table, loc)
+	default:
+		return Result{}, fmt.Errorf("unknown import source %q, use strong, hevy or fitnotes", source)
+	}
+}
+
+// table is a CSV file with its columns looked up by header name
+type table struct {
+	columns map[string]int
+	rows    [][]string
+}
+
+// readTable reads a CSV export separated by commas or semicolons, the
+// separator is guessed from the header line
+func readTable(r io.Reader) (table, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return table{}, err
+	}
+	content := strings.TrimPrefix(string(data), "\ufeff")
+
+	header, _, _ := strings.Cut(content, "\n")
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if strings.Count(header, ";") > strings.Count(header, ",") {
+		reader.Comma = ';'
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return table{}, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return table{}, errors.New("the file is empty")
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	return table{columns: columns, rows: records[1:]}, nil
+}
+
+// has tells whether the export has a column
+func (t table) has(column string) bool {
+	_, ok := t.columns[column]
+	return ok
+}
+
+// require fails with the first missing column, which usually means the
+// file was exported by another app than the one chosen
+func (t table) require(source Source, columns ...string) error {
+	for _, column := range columns {
+		if !t.has(column) {
+			return fmt.Errorf("missing column %q, is this a %s export?", column, source)
+		}
+	}
+	return nil
+}
+
+// value returns a trimmed cell, empty when the row is too short
+func (t table) value(row []string, column string) string {
+	i, ok := t.columns[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// sessionBuilder groups rows into sessions and their exercises in the order
+// they first appear
+type sessionBuilder struct {
+	result   Result
+	sessions map[string]int
+	entries  map[string]map[string]int
+}
+
+func newSessionBuilder() *sessionBuilder {
+	return &sessionBuilder{
+		sessions: make(map[string]int),
+		entries:  make(map[string]map[string]int),
+	}
+}
+
+// add appends a set to the exercise of the session key, creating both on first sight
+func (b *sessionBuilder) add(key string, session Session, exercise string, set Set) {
+	index, ok := b.sessions[key]
+	if !ok {
+		index = len(b.result.Sessions)
+		b.sessions[key] = index
+		b.entries[key] = make(map[string]int)
+		b.result.Sessions = append(b.result.Sessions, session)
+	}
+
+	entries := &b.result.Sessions[index].Entries
+	entry, ok := b.entries[key][exercise]
+	if !ok {
+		entry = len(*entries)
+		b.entries[key][exercise] = entry
+		*entries = append(*entries, Entry{Exercise: exercise})
+	}
+	(*entries)[entry].Sets = append((*entries)[entry].Sets, set)
+}
+
+// skip counts a set that isn't imported
+func (b *sessionBuilder) skip() {
+	b.result.SkippedSets++
+}
+
+// parseSet reads reps and a weight in unit. Sets without reps return false,
+// an empty weight is bodyweight.
+func parseSet(reps string, weight string, unit units.Unit) (Set, bool, error) {
+	count, err := parseNumber(reps)
+	if err != nil {
+		return Set{}, false, fmt.Errorf("invalid reps %q", reps)
+	}
+	if count < 1 {
+		return Set{}, false, nil
+	}
+
+	set := Set{Reps: int(count)}
+	if weight != "" {
+		parsed, err := decimal.Parse(strings.ReplaceAll(weight, ",", "."))
+		if err != nil {
+			return Set{}, false, fmt.Errorf("invalid weight %q", weight)
+		}
+		if parsed < 0 {
+			parsed = 0
+		}
+		set.Weight = units.ToKilograms(parsed, unit)
+	}
+	return set, true, nil
+}
+
+// parseNumber reads a count that some apps write with decimals, empty is zero
+func parseNumber(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+}
+
+// parseTime tries the layouts an app writes times in, reading them in loc
+func parseTime(value string, loc *time.Location, layouts ...string) (time.Time, error) {
+	for _, layout := range layouts {
+		if parsed, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// rowError points a parse error at its line, counting the header as line 1
+func rowError(i int, err error) error {
+	return fmt.Errorf("line %d: %w", i+2, err)
+}
@@ -0,0 +1,138 @@
+package importer
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MatchThreshold is the lowest similarity an exercise is suggested at, names
+// matching nothing this well have to be mapped by hand
+const MatchThreshold = 0.5
+
+// Candidate is a catalog exercise names are matched against
+type Candidate struct {
+	ID   uint
+	Name string
+}
+
+// Match is the catalog exercise closest to a name, Score runs from 0 for
+// nothing in common to 1 for the same words
+type Match struct {
+	ExerciseID uint
+	Score      float64
+}
+
+// synonyms spell out abbreviations and join the spellings apps disagree on
+var synonyms = map[string]string{
+	"db":        "dumbbell",
+	"dumbbells": "dumbbell",
+	"bb":        "barbell",
+	"kb":        "kettlebell",
+	"pullup":    "pull up",
+	"pullups":   "pull up",
+	"chinup":    "chin up",
+	"chinups":   "chin up",
+	"pushup":    "push up",
+	"pushups":   "push up",
+	"situp":     "sit up",
+	"situps":    "sit up",
+	"ohp":       "overhead press",
+	"rdl":       "romanian deadlift",
+}
+
+// stopWords carry nothing about the exercise
+var stopWords = map[string]bool{
+	"a":    true,
+	"and":  true,
+	"of":   true,
+	"on":   true,
+	"the":  true,
+	"to":   true,
+	"with": true,
+}
+
+// BestMatch finds the candidate most similar to name. Ties go to the shorter
+// name, which is usually the plain variant. Returns false below MatchThreshold.
+func BestMatch(name string, candidates []Candidate) (Match, bool) {
+	words := normalize(name)
+
+	best := Match{}
+	bestLength := 0
+	for _, candidate := range candidates {
+		score := similarity(words, normalize(candidate.Name))
+		if score > best.Score || (score == best.Score && score > 0 && len(candidate.Name) < bestLength) {
+			best = Match{ExerciseID: candidate.ID, Score: score}
+			bestLength = len(candidate.Name)
+		}
+	}
+
+	return best, best.Score >= MatchThreshold
+}
+
+// normalize splits a name into lowercase words with synonyms spelled out and
+// stop words dropped, so "DB Bench Press (Incline)" and "Incline Dumbbell
+// Bench Press" end up as the same words
+func normalize(name string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var words []string
+	for _, field := range fields {
+		if synonym, ok := synonyms[field]; ok {
+			words = append(words, strings.Fields(synonym)...)
+			continue
+		}
+		if !stopWords[field] {
+			words = append(words, field)
+		}
+	}
+	return words
+}
+
+// similarity mostly compares which words two names share, ignoring their
+// order, and partly their letters so typos and plurals still score
+func similarity(a []string, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	return 0.7*dice(wordSet(a), wordSet(b)) + 0.3*dice(bigrams(a), bigrams(b))
+}
+
+// dice is the Sørensen–Dice coefficient of two sets
+func dice(a map[string]bool, b map[string]bool) float64 {
+	if len(a)+len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for item := range a {
+		if b[item] {
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)+len(b))
+}
+
+func wordSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// bigrams are the letter pairs of the words sorted and joined, so word
+// order doesn't matter
+func bigrams(words []string) map[string]bool {
+	sorted := append([]string(nil), words...)
+	sort.Strings(sorted)
+	joined := []rune(strings.Join(sorted, " "))
+
+	pairs := make(map[string]bool, len(joined))
+	for i := 0; i+1 < len(joined); i++ {
+		pairs[string(joined[i:i+2])] = true
+	}
+	return pairs
+}
@@ -0,0 +1,75 @@
+package importer
+
+import "testing"
+
+var testCandidates = []Candidate{
+	{ID: 1, Name: "Bench Press"},
+	{ID: 2, Name: "Incline Dumbbell Bench Press"},
+	{ID: 3, Name: "Pull Up"},
+	{ID: 4, Name: "Deadlift"},
+	{ID: 5, Name: "Romanian Deadlift"},
+	{ID: 6, Name: "Overhead Press"},
+	{ID: 7, Name: "Barbell Squat"},
+	{ID: 8, Name: "Squat"},
+}
+
+func TestBestMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		want      uint
+		wantOK    bool
+		wantExact bool
+	}{
+		{name: "Deadlift", want: 4, wantOK: true, wantExact: true},
+		{name: "deadlift", want: 4, wantOK: true, wantExact: true},
+		{name: "Bench Press (Barbell)", want: 1, wantOK: true},
+		{name: "DB Bench Press (Incline)", want: 2, wantOK: true, wantExact: true},
+		{name: "Pullups", want: 3, wantOK: true, wantExact: true},
+		{name: "Pull-Up", want: 3, wantOK: true, wantExact: true},
+		{name: "RDL", want: 5, wantOK: true, wantExact: true},
+		{name: "OHP", want: 6, wantOK: true, wantExact: true},
+		{name: "Squat (Barbell)", want: 7, wantOK: true, wantExact: true},
+		{name: "Barbell Back Squat", want: 7, wantOK: true},
+		{name: "Zumba", wantOK: false},
+		{name: "", wantOK: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match, ok := BestMatch(test.name, testCandidates)
+			if ok != test.wantOK {
+				t.Fatalf("BestMatch(%q) ok = %v with %+v, want %v", test.name, ok, match, test.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if match.ExerciseID != test.want {
+				t.Errorf("BestMatch(%q) = exercise %d, want %d", test.name, match.ExerciseID, test.want)
+			}
+			if test.wantExact && match.Score != 1 {
+				t.Errorf("BestMatch(%q) score = %v, want 1", test.name, match.Score)
+			}
+			if match.Score < MatchThreshold || match.Score > 1 {
+				t.Errorf("BestMatch(%q) score = %v, out of range", test.name, match.Score)
+			}
+		})
+	}
+}
+
+func TestBestMatchPrefersShorterNameOnTies(t *testing.T) {
+	candidates := []Candidate{
+		{ID: 1, Name: "Curl (Dumbbell)"},
+		{ID: 2, Name: "DB Curl"},
+	}
+
+	match, ok := BestMatch("Dumbbell Curl", candidates)
+	if !ok || match.ExerciseID != 2 {
+		t.Fatalf("BestMatch() = %+v, %v, want exercise 2", match, ok)
+	}
+}
+
+func TestBestMatchWithoutCandidates(t *testing.T) {
+	if match, ok := BestMatch("Bench Press", nil); ok {
+		t.Fatalf("BestMatch() = %+v, want no match", match)
+	}
+}
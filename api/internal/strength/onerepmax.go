@@ -0,0 +1,46 @@
+package strength
+
+import (
+	"fmt"
+
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+)
+
+type Formula string
+
+const (
+	Epley   Formula = "epley"
+	Brzycki Formula = "brzycki"
+)
+
+// ParseFormula maps a query value to a formula, defaulting to Epley when empty
+func ParseFormula(value string) (Formula, error) {
+	switch Formula(value) {
+	case "", Epley:
+		return Epley, nil
+	case Brzycki:
+		return Brzycki, nil
+	default:
+		return "", fmt.Errorf("unknown one-rep-max formula %q, use epley or brzycki", value)
+	}
+}
+
+// EstimateOneRepMax estimates the one-rep max from a set of reps at a weight,
+// rounded like any other decimal
+func EstimateOneRepMax(weight decimal.Decimal, reps int, formula Formula) decimal.Decimal {
+	if reps <= 0 {
+		return 0
+	}
+	if reps == 1 {
+		return weight
+	}
+
+	switch formula {
+	case Brzycki:
+		// The formula breaks down past 36 reps, cap it there
+		reps = min(reps, 36)
+		return weight.MulFloat(36 / float64(37-reps))
+	default:
+		return weight.MulFloat(1 + float64(reps)/30)
+	}
+}
@@ -0,0 +1,195 @@
+package strength
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/nagy135/fitness-tracker/dto"
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"github.com/nagy135/fitness-tracker/models"
+)
+
+// ComputeExercisePRs finds the personal records of one exercise across all
+// of its records. Set volumes and session volumes honor the exercise's
+// TotalWeightMultiplier, weights and estimated maxes are reported as logged.
+// Sessions are the days of loc.
+func ComputeExercisePRs(records []models.Record, exercise models.Exercise, formula Formula, loc *time.Location) dto.ExercisePRsDto {
+	prs := dto.ExercisePRsDto{
+		Formula: string(formula),
+		RepPRs:  []dto.PRSetDto{},
+	}
+
+	repPRs := make(map[decimal.Decimal]dto.PRSetDto)
+	sessionVolumes := make(map[string]*dto.SessionPRDto)
+
+	for _, record := range records {
+		date := RecordDay(record, loc)
+
+		session, exists := sessionVolumes[date]
+		if !exists {
+			session = &dto.SessionPRDto{Date: date}
+			sessionVolumes[date] = session
+		}
+		session.RecordIDs = append(session.RecordIDs, record.ID)
+
+		for _, set := range record.Sets {
+			if set.Reps <= 0 {
+				continue
+			}
+
+			setVolume := set.Weight.MulInt(set.Reps).MulFloat(float64(exercise.TotalWeightMultiplier))
+			session.Value += setVolume
+
+			candidate := dto.PRSetDto{
+				Weight:   set.Weight,
+				Reps:     set.Reps,
+				RecordID: record.ID,
+				SetID:    set.ID,
+				Date:     date,
+			}
+
+			// Ties go to the earliest set, later ones only match the record
+			oneRepMax := candidate
+			oneRepMax.Value = EstimateOneRepMax(set.Weight, set.Reps, formula)
+			if IsBetterPR(prs.EstimatedOneRepMax, oneRepMax) {
+				prs.EstimatedOneRepMax = &oneRepMax
+			}
+
+			// Singles are sets of exactly one rep, heavier sets of more reps
+			// already count towards the estimated max
+			if set.Reps == 1 {
+				heaviest := candidate
+				heaviest.Value = set.Weight
+				if IsBetterPR(prs.HeaviestSingle, heaviest) {
+					prs.HeaviestSingle = &heaviest
+				}
+			}
+
+			volume := candidate
+			volume.Value = setVolume
+			if IsBetterPR(prs.BestSetVolume, volume) {
+				prs.BestSetVolume = &volume
+			}
+
+			repPR := candidate
+			repPR.Value = decimal.FromInt(int64(set.Reps))
+			if existing, ok := repPRs[set.Weight]; !ok || IsBetterPR(&existing, repPR) {
+				repPRs[set.Weight] = repPR
+			}
+		}
+	}
+
+	for _, session := range sessionVolumes {
+		if prs.BestSessionVolume == nil ||
+			session.Value > prs.BestSessionVolume.Value ||
+			(session.Value == prs.BestSessionVolume.Value && session.Date < prs.BestSessionVolume.Date) {
+			prs.BestSessionVolume = session
+		}
+	}
+
+	for _, repPR := range repPRs {
+		prs.RepPRs = append(prs.RepPRs, repPR)
+	}
+	sort.Slice(prs.RepPRs, func(i, j int) bool {
+		return prs.RepPRs[i].Weight < prs.RepPRs[j].Weight
+	})
+
+	return prs
+}
+
+// DetectPersonalRecords compares the PRs of an exercise with and without a
+// record and returns the ones the record beat. Estimated maxes use Epley.
+// Rep PRs only count at weights that were lifted before.
+func DetectPersonalRecords(prior []models.Record, record models.Record, exercise models.Exercise, loc *time.Location) []models.PersonalRecord {
+	before := ComputeExercisePRs(prior, exercise, Epley, loc)
+	after := ComputeExercisePRs(append(prior[:len(prior):len(prior)], record), exercise, Epley, loc)
+
+	date := RecordTime(record)
+
+	var detected []models.PersonalRecord
+	newPR := func(prType models.PRType, current *dto.PRSetDto, previous *dto.PRSetDto) {
+		if current == nil || current.RecordID != record.ID {
+			return
+		}
+		if previous != nil && current.Value <= previous.Value {
+			return
+		}
+
+		weight := current.Weight
+		reps := current.Reps
+		setID := current.SetID
+		pr := models.PersonalRecord{
+			UserID:     record.UserID,
+			ExerciseID: exercise.ID,
+			Type:       prType,
+			Value:      current.Value,
+			Weight:     &weight,
+			Reps:       &reps,
+			RecordID:   record.ID,
+			SetID:      &setID,
+			Date:       date,
+		}
+		if previous != nil {
+			previousValue := previous.Value
+			pr.PreviousValue = &previousValue
+		}
+		detected = append(detected, pr)
+	}
+
+	newPR(models.EstimatedOneRepMaxPR, after.EstimatedOneRepMax, before.EstimatedOneRepMax)
+	newPR(models.HeaviestSinglePR, after.HeaviestSingle, before.HeaviestSingle)
+	newPR(models.BestSetVolumePR, after.BestSetVolume, before.BestSetVolume)
+
+	previousReps := make(map[decimal.Decimal]dto.PRSetDto, len(before.RepPRs))
+	for _, repPR := range before.RepPRs {
+		previousReps[repPR.Weight] = repPR
+	}
+	for _, repPR := range after.RepPRs {
+		if previous, ok := previousReps[repPR.Weight]; ok {
+			newPR(models.RepPR, &repPR, &previous)
+		}
+	}
+
+	if session := after.BestSessionVolume; session != nil && slices.Contains(session.RecordIDs, record.ID) {
+		previous := before.BestSessionVolume
+		if previous == nil || session.Value > previous.Value {
+			pr := models.PersonalRecord{
+				UserID:     record.UserID,
+				ExerciseID: exercise.ID,
+				Type:       models.BestSessionVolumePR,
+				Value:      session.Value,
+				RecordID:   record.ID,
+				Date:       date,
+			}
+			if previous != nil {
+				previousValue := previous.Value
+				pr.PreviousValue = &previousValue
+			}
+			detected = append(detected, pr)
+		}
+	}
+
+	return detected
+}
+
+// IsBetterPR reports whether candidate beats current, earlier dates win ties
+func IsBetterPR(current *dto.PRSetDto, candidate dto.PRSetDto) bool {
+	if current == nil || candidate.Value > current.Value {
+		return true
+	}
+	return candidate.Value == current.Value && candidate.Date < current.Date
+}
+
+// RecordTime is the point in time a record counts towards
+func RecordTime(record models.Record) time.Time {
+	if record.Date != nil {
+		return *record.Date
+	}
+	return record.CreatedAt
+}
+
+// RecordDay is the calendar day in loc a record counts towards
+func RecordDay(record models.Record, loc *time.Location) string {
+	return RecordTime(record).In(loc).Format("2006-01-02")
+}
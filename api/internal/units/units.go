@@ -0,0 +1,63 @@
+package units
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+)
+
+// Unit is a weight unit. Weights are stored in kilograms and converted from
+// and to the user's unit at the API boundary.
+type Unit string
+
+const (
+	Kilograms Unit = "kg"
+	Pounds    Unit = "lb"
+)
+
+const poundsPerKilogram = 2.20462262185
+
+// ParseUnit maps a stored or requested value to a unit, defaulting to kilograms when empty
+func ParseUnit(value string) (Unit, error) {
+	switch Unit(value) {
+	case "", Kilograms:
+		return Kilograms, nil
+	case Pounds:
+		return Pounds, nil
+	default:
+		return "", fmt.Errorf("unknown weight unit %q, use kg or lb", value)
+	}
+}
+
+// ToKilograms converts a weight given in unit to kilograms
+func ToKilograms(weight decimal.Decimal, unit Unit) decimal.Decimal {
+	if unit == Pounds {
+		return weight.MulFloat(1 / poundsPerKilogram)
+	}
+	return weight
+}
+
+// FromKilograms converts a stored weight to unit. Pounds are rounded to two
+// places so weights entered in pounds read back as entered.
+func FromKilograms(weight decimal.Decimal, unit Unit) decimal.Decimal {
+	if unit == Pounds {
+		return weight.MulFloat(poundsPerKilogram).Round(2)
+	}
+	return weight
+}
+
+// PlateIncrement is the smallest step a barbell can be loaded in, a pair of
+// the smallest common plates
+func PlateIncrement(unit Unit) decimal.Decimal {
+	if unit == Pounds {
+		return decimal.FromInt(5)
+	}
+	return decimal.FromFloat(2.5)
+}
+
+// RoundToPlates rounds a weight in unit to the nearest loadable weight
+func RoundToPlates(weight decimal.Decimal, unit Unit) decimal.Decimal {
+	increment := PlateIncrement(unit)
+	return increment.MulInt(int(math.Round(weight.Float64() / increment.Float64())))
+}
@@ -0,0 +1,126 @@
+package units
+
+import (
+	"testing"
+
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+)
+
+func TestParseUnit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Unit
+		wantErr bool
+	}{
+		{value: "", want: Kilograms},
+		{value: "kg", want: Kilograms},
+		{value: "lb", want: Pounds},
+		{value: "lbs", wantErr: true},
+		{value: "KG", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseUnit(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseUnit(%q) error = %v, want error %v", test.value, err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("ParseUnit(%q) = %q, want %q", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestToKilograms(t *testing.T) {
+	tests := []struct {
+		weight string
+		unit   Unit
+		want   string
+	}{
+		{"100", Kilograms, "100"},
+		{"102.5", Kilograms, "102.5"},
+		{"225", Pounds, "102.058"},
+		{"45", Pounds, "20.412"},
+		{"2.5", Pounds, "1.134"},
+		{"0", Pounds, "0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.weight+string(test.unit), func(t *testing.T) {
+			got := ToKilograms(mustParse(t, test.weight), test.unit)
+			if got.String() != test.want {
+				t.Errorf("ToKilograms(%s %s) = %s kg, want %s", test.weight, test.unit, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFromKilograms(t *testing.T) {
+	tests := []struct {
+		weight string
+		unit   Unit
+		want   string
+	}{
+		{"102.058", Kilograms, "102.058"},
+		{"100", Pounds, "220.46"},
+		{"20", Pounds, "44.09"},
+		{"102.058", Pounds, "225"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.weight+string(test.unit), func(t *testing.T) {
+			got := FromKilograms(mustParse(t, test.weight), test.unit)
+			if got.String() != test.want {
+				t.Errorf("FromKilograms(%s kg, %s) = %s, want %s", test.weight, test.unit, got, test.want)
+			}
+		})
+	}
+}
+
+// Weights entered in pounds read back as entered, despite being stored in
+// kilograms with three places
+func TestPoundsRoundTrip(t *testing.T) {
+	for _, weight := range []string{"0.5", "1", "2.5", "5", "45", "95", "135", "185", "202.5", "225", "315", "405", "1005.25"} {
+		t.Run(weight, func(t *testing.T) {
+			entered := mustParse(t, weight)
+			got := FromKilograms(ToKilograms(entered, Pounds), Pounds)
+			if got != entered {
+				t.Errorf("%s lb read back as %s lb", entered, got)
+			}
+		})
+	}
+}
+
+func TestRoundToPlates(t *testing.T) {
+	tests := []struct {
+		weight string
+		unit   Unit
+		want   string
+	}{
+		{"101", Kilograms, "100"},
+		{"101.3", Kilograms, "102.5"},
+		{"101.25", Kilograms, "102.5"},
+		{"183", Pounds, "185"},
+		{"182", Pounds, "180"},
+		{"0.4", Kilograms, "0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.weight+string(test.unit), func(t *testing.T) {
+			got := RoundToPlates(mustParse(t, test.weight), test.unit)
+			if got.String() != test.want {
+				t.Errorf("RoundToPlates(%s %s) = %s, want %s", test.weight, test.unit, got, test.want)
+			}
+		})
+	}
+}
+
+func mustParse(t *testing.T, value string) decimal.Decimal {
+	t.Helper()
+	parsed, err := decimal.Parse(value)
+	if err != nil {
+		t.Fatalf("decimal.Parse(%q) error = %v", value, err)
+	}
+	return parsed
+}
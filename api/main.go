@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	_ "time/tzdata" // user timezones must resolve in images without zoneinfo
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -39,9 +40,10 @@ func main() {
 
 	// Add middleware
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: cfg.Server.AllowOrigins,
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
+		AllowOrigins:  cfg.Server.AllowOrigins,
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, If-Match, Idempotency-Key",
+		AllowMethods:  "GET, POST, PUT, PATCH, DELETE, OPTIONS",
+		ExposeHeaders: "ETag, Idempotent-Replayed",
 	}))
 	app.Use(logger.New())
 	app.Use(recover.New())
@@ -10,6 +10,7 @@ type AsyncJobType string
 
 const (
 	FetchExercises AsyncJobType = "fetch-exercises"
+	ImportHistory  AsyncJobType = "import-history"
 )
 
 type Status string
@@ -12,6 +12,10 @@ type Exercise struct {
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
 
+	Version uint `json:"version" gorm:"not null;default:1"`
+
+	ChangeXID int64 `json:"-" gorm:"column:change_xid;not null;default:0;index"`
+
 	// Required field
 	Name string `json:"name" gorm:"not null"`
 
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"gorm.io/gorm"
+)
+
+type GoalType string
+
+const (
+	OneRepMaxGoal  GoalType = "estimated-1rm"
+	SessionsGoal   GoalType = "sessions"
+	BodyweightGoal GoalType = "bodyweight"
+)
+
+// Goal is a target the user works towards. Estimated 1RM goals need an
+// exercise, session goals a week or month period to count sessions in and
+// bodyweight goals are tracked from logged entries. StartValue is where the
+// user stood when the goal was set.
+type Goal struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	UserID uint     `json:"userId" gorm:"index"`
+	Type   GoalType `json:"type" gorm:"not null"`
+
+	ExerciseID *uint     `json:"exerciseId,omitempty"`
+	Exercise   *Exercise `json:"exercise,omitempty"`
+	Period     *string   `json:"period,omitempty"`
+
+	Target     decimal.Decimal `json:"target"`
+	StartValue decimal.Decimal `json:"startValue"`
+	Deadline   *time.Time      `json:"deadline,omitempty"`
+
+	Entries []GoalEntry `json:"entries,omitempty"`
+}
+
+// GoalEntry is a value logged towards a goal that isn't derived from training
+// data, such as a bodyweight measurement
+type GoalEntry struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	GoalID uint            `json:"goalId" gorm:"index"`
+	Value  decimal.Decimal `json:"value"`
+	Date   time.Time       `json:"date"`
+}
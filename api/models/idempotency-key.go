@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response to a create request, a retry sent
+// with the same Idempotency-Key gets it replayed instead of creating a duplicate
+type IdempotencyKey struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+
+	UserID uint   `json:"userId" gorm:"uniqueIndex:idx_idempotency_keys_user_key"`
+	Key    string `json:"key" gorm:"size:255;uniqueIndex:idx_idempotency_keys_user_key"`
+
+	// Fingerprint of the request the key was first used with, the key can't
+	// be reused for a different one
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	RequestHash string `json:"requestHash"`
+
+	// Stored response, StatusCode stays nil while the first request runs
+	StatusCode  *int   `json:"statusCode,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	ETag        string `json:"etag,omitempty" gorm:"column:etag"`
+	Body        []byte `json:"-"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Import is a CSV export of another app uploaded to be imported. The file is
+// kept so the import can be previewed, remapped and run again.
+type Import struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	UserID   uint   `json:"userId" gorm:"index"`
+	Source   string `json:"source"`
+	FileName string `json:"fileName"`
+	Data     []byte `json:"-"`
+
+	// Job of the latest run, a dry run only fills in the summary
+	AsyncJobID *uint     `json:"asyncJobId,omitempty"`
+	AsyncJob   *AsyncJob `json:"asyncJob,omitempty" gorm:"foreignKey:AsyncJobID"`
+	DryRun     bool      `json:"dryRun"`
+
+	// Database field (JSON string)
+	SummaryDB *string `json:"-" gorm:"column:summary;type:text"`
+
+	// API field (parsed summary) - not stored in DB
+	Summary *ImportSummary `json:"summary,omitempty" gorm:"-"`
+}
+
+// ImportSummary is what the latest run of an import found, or created when
+// it wasn't a dry run
+type ImportSummary struct {
+	Workouts    int `json:"workouts"`
+	Records     int `json:"records"`
+	Sets        int `json:"sets"`
+	Duplicates  int `json:"duplicates"`
+	SkippedSets int `json:"skippedSets"`
+
+	// Records left out because their exercise isn't mapped, or on a real run
+	// isn't confirmed
+	UnmappedRecords int `json:"unmappedRecords"`
+
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+
+	Exercises []ImportExerciseSummary `json:"exercises"`
+}
+
+// ImportExerciseSummary is one exercise name of an export and where it goes,
+// a suggestion until the user confirmed it
+type ImportExerciseSummary struct {
+	Name       string `json:"name"`
+	ExerciseID *uint  `json:"exerciseId,omitempty"`
+	Confirmed  bool   `json:"confirmed"`
+	Records    int    `json:"records"`
+}
+
+// AfterFind GORM hook - automatically called after loading from database
+func (i *Import) AfterFind(tx *gorm.DB) error {
+	if i.SummaryDB != nil && *i.SummaryDB != "" {
+		json.Unmarshal([]byte(*i.SummaryDB), &i.Summary)
+	}
+
+	return nil
+}
+
+// ExerciseMapping maps an exercise name of another app onto the catalog.
+// Suggestions come from fuzzy matching, confirmed mappings are kept as they
+// are for every later import. An empty ExerciseID skips the exercise.
+type ExerciseMapping struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	UserID uint   `json:"userId" gorm:"uniqueIndex:idx_exercise_mappings_user_source_name"`
+	Source string `json:"source" gorm:"uniqueIndex:idx_exercise_mappings_user_source_name"`
+	Name   string `json:"name" gorm:"uniqueIndex:idx_exercise_mappings_user_source_name"`
+
+	ExerciseID *uint     `json:"exerciseId,omitempty"`
+	Exercise   *Exercise `json:"exercise,omitempty" gorm:"foreignKey:ExerciseID"`
+
+	// Similarity of the suggested exercise, from 0 to 1
+	Score     float64 `json:"score"`
+	Confirmed bool    `json:"confirmed" gorm:"default:false"`
+}
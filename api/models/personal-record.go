@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"gorm.io/gorm"
+)
+
+type PRType string
+
+const (
+	EstimatedOneRepMaxPR PRType = "estimated-1rm"
+	HeaviestSinglePR     PRType = "heaviest-single"
+	BestSetVolumePR      PRType = "best-set-volume"
+	BestSessionVolumePR  PRType = "best-session-volume"
+	RepPR                PRType = "rep-pr"
+)
+
+// PersonalRecord is a PR detected when a record was logged, together with
+// the value it beat. SetID is empty for session volume PRs.
+type PersonalRecord struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	UserID     uint   `json:"userId" gorm:"index:idx_personal_records_user_exercise"`
+	ExerciseID uint   `json:"exerciseId" gorm:"index:idx_personal_records_user_exercise"`
+	Type       PRType `json:"type"`
+
+	Value         decimal.Decimal  `json:"value"`
+	PreviousValue *decimal.Decimal `json:"previousValue,omitempty"`
+	Weight        *decimal.Decimal `json:"weight,omitempty"`
+	Reps          *int             `json:"reps,omitempty"`
+
+	RecordID uint      `json:"recordId" gorm:"index"`
+	SetID    *uint     `json:"setId,omitempty"`
+	Date     time.Time `json:"date"`
+}
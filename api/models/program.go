@@ -0,0 +1,115 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"gorm.io/gorm"
+)
+
+// Program is a multi-week plan of days, each based on a workout template.
+// NextDayIndex points at the next day to train across all weeks, Cycle
+// counts how many times the whole program has been completed.
+type Program struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	UserID      uint    `json:"userId" gorm:"index"`
+	Name        string  `json:"name" gorm:"not null"`
+	Description *string `json:"description,omitempty"`
+
+	NextDayIndex int `json:"nextDayIndex" gorm:"default:0"`
+	Cycle        int `json:"cycle" gorm:"default:0"`
+
+	Weeks         []ProgramWeek         `json:"weeks"`
+	TrainingMaxes []ProgramTrainingMax  `json:"trainingMaxes"`
+	Rules         []ProgramProgressRule `json:"rules"`
+}
+
+type ProgramWeek struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	ProgramID uint         `json:"programId" gorm:"index"`
+	Number    int          `json:"number"`
+	Days      []ProgramDay `json:"days"`
+}
+
+type ProgramDay struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	ProgramWeekID     uint            `json:"programWeekId" gorm:"index"`
+	Position          int             `json:"position"`
+	Label             string          `json:"label"`
+	WorkoutTemplateID uint            `json:"workoutTemplateId"`
+	WorkoutTemplate   WorkoutTemplate `json:"workoutTemplate" gorm:"foreignKey:WorkoutTemplateID"`
+
+	Prescriptions []ProgramPrescription `json:"prescriptions"`
+}
+
+// ProgramPrescription overrides the template targets of an exercise on a
+// given day, optionally as a percentage of the exercise's training max
+type ProgramPrescription struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	ProgramDayID         uint     `json:"programDayId" gorm:"index"`
+	ExerciseID           uint     `json:"exerciseId"`
+	Exercise             Exercise `json:"exercise" gorm:"foreignKey:ExerciseID"`
+	Sets                 int      `json:"sets"`
+	Reps                 int      `json:"reps"`
+	PercentOfTrainingMax *float32 `json:"percentOfTrainingMax,omitempty"`
+	Amrap                bool     `json:"amrap"` // last set is as many reps as possible
+}
+
+// ProgramTrainingMax is the current working max of an exercise within a
+// program, Failures counts consecutive failed sessions since the last change
+type ProgramTrainingMax struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	ProgramID  uint            `json:"programId" gorm:"index"`
+	ExerciseID uint            `json:"exerciseId"`
+	Weight     decimal.Decimal `json:"weight"`
+	Failures   int             `json:"failures" gorm:"default:0"`
+}
+
+// ProgramProgressRule adds Increment to the training max after a successful
+// session and deloads by DeloadPercent after FailureThreshold failures in a row
+type ProgramProgressRule struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	ProgramID        uint            `json:"programId" gorm:"index"`
+	ExerciseID       uint            `json:"exerciseId"`
+	Increment        decimal.Decimal `json:"increment"`
+	FailureThreshold int             `json:"failureThreshold"`
+	DeloadPercent    float32         `json:"deloadPercent"`
+}
+
+// ProgramSession records a completed program day and the workout that fulfilled it
+type ProgramSession struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	ProgramID    uint `json:"programId" gorm:"uniqueIndex:idx_program_session_workout"`
+	ProgramDayID uint `json:"programDayId"`
+	WorkoutID    uint `json:"workoutId" gorm:"uniqueIndex:idx_program_session_workout"`
+	Cycle        int  `json:"cycle"`
+	Succeeded    bool `json:"succeeded"`
+}
@@ -12,9 +12,28 @@ type Record struct {
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
 
+	Version uint `json:"version" gorm:"not null;default:1"`
+
+	// ID given by the client that created the record offline, sync uses it to
+	// find the record again when a mutation is retried. Unique per user.
+	ClientID *string `json:"clientId,omitempty" gorm:"size:64;uniqueIndex:idx_records_user_client,priority:2"`
+
+	ChangeXID int64 `json:"-" gorm:"column:change_xid;not null;default:0;index"`
+
 	ExerciseID uint     `json:"exerciseId"`
 	Exercise   Exercise `json:"exercise" gorm:"foreignKey:ExerciseID"`
-	UserID     uint     `json:"userId"`
+	UserID     uint     `json:"userId" gorm:"index:idx_records_user_date;uniqueIndex:idx_records_user_client,priority:1"`
 	Sets       []Set    `json:"sets"`
-	Date       *time.Time `json:"date,omitempty"`
+	Date       *time.Time `json:"date,omitempty" gorm:"index:idx_records_user_date"`
+
+	// Optional parent workout, Position orders records within it
+	WorkoutID *uint `json:"workoutId,omitempty" gorm:"index"`
+	Position  int   `json:"position" gorm:"default:0"`
+
+	// Superset, giant set or circuit the record is part of within its workout
+	WorkoutGroupID *uint `json:"workoutGroupId,omitempty" gorm:"index"`
+
+	// Placeholder records are pre-filled from a template and not yet performed,
+	// they are left out of stats and PRs
+	Placeholder bool `json:"placeholder" gorm:"default:false"`
 }
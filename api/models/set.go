@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/nagy135/fitness-tracker/internal/decimal"
 	"gorm.io/gorm"
 )
 
@@ -12,8 +13,19 @@ type Set struct {
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
 
-	Reps   int     `json:"reps"`
-	Weight float32 `json:"weight"`
+	// ID given by the client that created the set offline, unique per record
+	ClientID *string `json:"clientId,omitempty" gorm:"size:64;uniqueIndex:idx_sets_record_client,priority:2"`
 
-	RecordID uint `json:"recordId"`
+	ChangeXID int64 `json:"-" gorm:"column:change_xid;not null;default:0;index"`
+
+	Reps   int             `json:"reps"`
+	Weight decimal.Decimal `json:"weight"`
+
+	// When the set was performed during a live session
+	PerformedAt *time.Time `json:"performedAt,omitempty"`
+
+	RecordID uint `json:"recordId" gorm:"index;uniqueIndex:idx_sets_record_client,priority:1"`
+
+	// PR types the set achieved when it was logged, only filled in on write responses
+	PersonalRecords []PRType `json:"personalRecords,omitempty" gorm:"-"`
 } 
\ No newline at end of file
@@ -14,4 +14,9 @@ type User struct {
 
 	Name     string `json:"name"`
 	Password string `json:"-" gorm:"column:pass"` // Don't expose password in JSON
+
+	// Settings
+	WeeklySessionTarget int    `json:"weeklySessionTarget" gorm:"not null;default:3"`
+	Unit                string `json:"unit" gorm:"not null;default:kg"`      // weights are stored in kg and shown in this unit
+	Timezone            string `json:"timezone" gorm:"not null;default:UTC"` // IANA name, days in stats are this timezone's days
 }
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WorkoutGroupType string
+
+const (
+	Superset WorkoutGroupType = "superset"
+	GiantSet WorkoutGroupType = "giant-set"
+	Circuit  WorkoutGroupType = "circuit"
+)
+
+// WorkoutGroup ties records of one workout that are performed back to back
+type WorkoutGroup struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	WorkoutID uint             `json:"workoutId" gorm:"index"`
+	Type      WorkoutGroupType `json:"type"`
+	Label     string           `json:"label"`
+}
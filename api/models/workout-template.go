@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/nagy135/fitness-tracker/internal/decimal"
+	"gorm.io/gorm"
+)
+
+type WorkoutTemplate struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	UserID uint   `json:"userId" gorm:"index"`
+	Name   string `json:"name" gorm:"not null"`
+
+	Exercises []WorkoutTemplateExercise `json:"exercises"`
+}
+
+// WorkoutTemplateExercise is a single ordered entry of a template with its targets
+type WorkoutTemplateExercise struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
+
+	WorkoutTemplateID uint     `json:"workoutTemplateId" gorm:"index"`
+	ExerciseID        uint     `json:"exerciseId"`
+	Exercise          Exercise `json:"exercise" gorm:"foreignKey:ExerciseID"`
+	Position          int      `json:"position"`
+
+	TargetSets   int              `json:"targetSets"`
+	TargetReps   int              `json:"targetReps"`
+	TargetWeight *decimal.Decimal `json:"targetWeight,omitempty"`
+}
@@ -12,9 +12,27 @@ type Workout struct {
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `json:"deletedAt,omitempty" gorm:"index"`
 
-	UserID     uint     `json:"userId"`
+	Version uint `json:"version" gorm:"not null;default:1"`
+
+	// ID given by the client that created the workout offline, unique per user
+	ClientID *string `json:"clientId,omitempty" gorm:"size:64;uniqueIndex:idx_workouts_user_client,priority:2"`
+
+	ChangeXID int64 `json:"-" gorm:"column:change_xid;not null;default:0;index"`
+
+	UserID     uint     `json:"userId" gorm:"index:idx_workouts_user_date;uniqueIndex:idx_workouts_user_client,priority:1"`
 
 	Label      string     `json:"label"`
 
-	Date       *time.Time `json:"date,omitempty"`
+	Date       *time.Time `json:"date,omitempty" gorm:"index:idx_workouts_user_date"`
+
+	// Live session timestamps, a workout is active between start and finish
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+
+	// Set when the workout was created from a program day
+	ProgramID    *uint `json:"programId,omitempty" gorm:"index"`
+	ProgramDayID *uint `json:"programDayId,omitempty"`
+
+	Records []Record       `json:"records,omitempty"`
+	Groups  []WorkoutGroup `json:"groups,omitempty"`
 }
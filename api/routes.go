@@ -15,7 +15,8 @@ func SetupRoutes(app *fiber.App, db *database.DBInstance, cfg *config.Config) {
 	authHandler := handlers.NewAuthHandler(db, cfg)
 	app.Post("/login", authHandler.Login)
 	app.Post("/refresh", authHandler.RefreshToken)
-	app.Post("/users", handlers.NewUserHandler(db).CreateUser)
+	userHandler := handlers.NewUserHandler(db)
+	app.Post("/users", userHandler.CreateUser)
 
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
@@ -34,26 +35,92 @@ func SetupRoutes(app *fiber.App, db *database.DBInstance, cfg *config.Config) {
 		},
 	}))
 
+	app.Get("/users/me", userHandler.GetMe)
+	app.Put("/users/me/settings", userHandler.UpdateSettings)
+
+	// Create endpoints replay their response to retries with an Idempotency-Key
+	idempotencyHandler := handlers.NewIdempotencyHandler(db, cfg)
+
 	exerciseHandler := handlers.NewExerciseHandler(db, cfg)
 	app.Get("/exercises", exerciseHandler.GetExercises)
 	app.Get("/exercises/options", exerciseHandler.GetExerciseOptions)
 	app.Get("/exercises/:id", exerciseHandler.GetExercise)
-	app.Post("/exercises", exerciseHandler.CreateExercise)
+	app.Post("/exercises", idempotencyHandler.Replay, exerciseHandler.CreateExercise)
 	app.Put("/exercises/:id", exerciseHandler.UpdateExercise)
 
 	recordHandler := handlers.NewRecordHandler(db)
 	app.Get("/records", recordHandler.GetRecords)
-	app.Post("/records", recordHandler.CreateRecord)
+	app.Post("/records", idempotencyHandler.Replay, recordHandler.CreateRecord)
+	app.Post("/records/batch", idempotencyHandler.Replay, recordHandler.CreateRecords)
+	app.Get("/records/:id", recordHandler.GetRecord)
 	app.Put("/records/:id", recordHandler.UpdateRecord)
+	app.Patch("/records/:id", recordHandler.PatchRecord)
+	app.Post("/records/:id/sets", recordHandler.AddRecordSet)
+	app.Patch("/records/:id/sets/:setId", recordHandler.UpdateRecordSet)
+	app.Delete("/records/:id/sets/:setId", recordHandler.DeleteRecordSet)
 	app.Get("/records/pr/:exerciseId", recordHandler.GetExercisePR)
+	app.Get("/records/pr/:exerciseId/history", recordHandler.GetExercisePRHistory)
+
+	syncHandler := handlers.NewSyncHandler(db, cfg)
+	app.Get("/sync", syncHandler.GetChanges)
+	app.Post("/sync", syncHandler.PushChanges)
 
 	asyncJobHandler := handlers.NewAsyncJobHandler(db)
 	app.Get("/async-jobs", asyncJobHandler.GetAsyncJobs)
-	app.Post("/async-jobs", asyncJobHandler.CreateAsyncJob)
+	app.Post("/async-jobs", idempotencyHandler.Replay, asyncJobHandler.CreateAsyncJob)
+
+	importHandler := handlers.NewImportHandler(db)
+	app.Get("/imports", importHandler.GetImports)
+	app.Get("/imports/:id", importHandler.GetImport)
+	app.Post("/imports", idempotencyHandler.Replay, importHandler.CreateImport)
+	app.Put("/imports/:id/mappings", importHandler.UpdateImportMappings)
+	app.Post("/imports/:id/run", importHandler.RunImport)
+
+	workoutTemplateHandler := handlers.NewWorkoutTemplateHandler(db)
+	app.Get("/workout-templates", workoutTemplateHandler.GetWorkoutTemplates)
+	app.Get("/workout-templates/:id", workoutTemplateHandler.GetWorkoutTemplate)
+	app.Post("/workout-templates", workoutTemplateHandler.CreateWorkoutTemplate)
+	app.Put("/workout-templates/:id", workoutTemplateHandler.UpdateWorkoutTemplate)
+	app.Delete("/workout-templates/:id", workoutTemplateHandler.DeleteWorkoutTemplate)
+
+	programHandler := handlers.NewProgramHandler(db)
+	app.Get("/programs", programHandler.GetPrograms)
+	app.Get("/programs/:id", programHandler.GetProgram)
+	app.Get("/programs/:id/today", programHandler.GetProgramToday)
+	app.Post("/programs", programHandler.CreateProgram)
+	app.Post("/programs/:id/today/workout", programHandler.StartProgramToday)
+	app.Post("/programs/:id/complete", programHandler.CompleteProgramDay)
+	app.Delete("/programs/:id", programHandler.DeleteProgram)
+
+	goalHandler := handlers.NewGoalHandler(db)
+	app.Get("/goals", goalHandler.GetGoals)
+	app.Get("/goals/:id", goalHandler.GetGoal)
+	app.Post("/goals", goalHandler.CreateGoal)
+	app.Put("/goals/:id", goalHandler.UpdateGoal)
+	app.Delete("/goals/:id", goalHandler.DeleteGoal)
+	app.Post("/goals/:id/entries", goalHandler.AddGoalEntry)
+
+	statsHandler := handlers.NewStatsHandler(db)
+	app.Get("/stats/muscles", statsHandler.GetMuscleStats)
+	app.Get("/stats/exercises/:id/series", statsHandler.GetExerciseSeries)
+	app.Get("/stats/load", statsHandler.GetLoadStats)
+	app.Get("/stats/consistency", statsHandler.GetConsistencyStats)
 
 	workoutHandler := handlers.NewWorkoutHandler(db)
 	app.Get("/workouts", workoutHandler.GetWorkouts)
 	app.Get("/workouts/stats", workoutHandler.GetWorkoutStats)
 	app.Get("/workouts/stats/:date", workoutHandler.GetWorkoutStatsByDate)
-	app.Post("/workouts", workoutHandler.CreateWorkout)
+	app.Get("/workouts/active", workoutHandler.GetActiveWorkout)
+	app.Get("/workouts/:id", workoutHandler.GetWorkout)
+	app.Post("/workouts", idempotencyHandler.Replay, workoutHandler.CreateWorkout)
+	app.Patch("/workouts/:id", workoutHandler.PatchWorkout)
+	app.Post("/workouts/from-template/:id", workoutTemplateHandler.CreateWorkoutFromTemplate)
+	app.Post("/workouts/:id/start", workoutHandler.StartWorkout)
+	app.Post("/workouts/:id/finish", workoutHandler.FinishWorkout)
+	app.Post("/workouts/:id/sets", workoutHandler.AddSessionSet)
+	app.Post("/workouts/:id/records", workoutHandler.AddWorkoutRecord)
+	app.Put("/workouts/:id/records/order", workoutHandler.ReorderWorkoutRecords)
+	app.Delete("/workouts/:id/records/:recordId", workoutHandler.RemoveWorkoutRecord)
+	app.Post("/workouts/:id/groups", workoutHandler.CreateWorkoutGroup)
+	app.Delete("/workouts/:id/groups/:groupId", workoutHandler.DeleteWorkoutGroup)
 }
@@ -0,0 +1,65 @@
+package utils
+
+import "time"
+
+// MaxDaysAhead is how far past the current day a logged date may be, planning
+// the coming week is fine, anything later is most likely a typo
+const MaxDaysAhead = 7
+
+// dateTimeLayouts are the ISO-8601 forms dates are accepted in. Layouts
+// without an offset are read in the user's timezone.
+var dateTimeLayouts = []struct {
+	layout string
+	local  bool
+}{
+	{time.RFC3339Nano, false},
+	{"2006-01-02T15:04:05", true},
+	{"2006-01-02T15:04", true},
+	{"2006-01-02", true},
+}
+
+// ParseDateTime parses an ISO-8601 date or datetime. Plain dates are midnight
+// and datetimes without an offset are wall time, both in loc.
+func ParseDateTime(field string, value string, loc *time.Location) (time.Time, *ValidationError) {
+	for _, format := range dateTimeLayouts {
+		var parsed time.Time
+		var err error
+		if format.local {
+			parsed, err = time.ParseInLocation(format.layout, value, loc)
+		} else {
+			parsed, err = time.Parse(format.layout, value)
+		}
+		if err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, &ValidationError{
+		Field:   field,
+		Tag:     "datetime",
+		Value:   value,
+		Message: "Invalid date, use YYYY-MM-DD or an ISO-8601 datetime like 2024-01-15T18:30:00+01:00",
+	}
+}
+
+// ParseLogDate parses the date something was performed on like ParseDateTime
+// and rejects dates more than MaxDaysAhead days after now's day in loc
+func ParseLogDate(field string, value string, loc *time.Location, now time.Time) (time.Time, *ValidationError) {
+	parsed, validationError := ParseDateTime(field, value, loc)
+	if validationError != nil {
+		return time.Time{}, validationError
+	}
+
+	local := now.In(loc)
+	limit := time.Date(local.Year(), local.Month(), local.Day()+MaxDaysAhead+1, 0, 0, 0, 0, loc)
+	if !parsed.Before(limit) {
+		return time.Time{}, &ValidationError{
+			Field:   field,
+			Tag:     "max",
+			Value:   value,
+			Message: "Date is too far in the future",
+		}
+	}
+
+	return parsed, nil
+}
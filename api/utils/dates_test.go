@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateTime(t *testing.T) {
+	prague := time.FixedZone("CEST", 2*60*60)
+
+	tests := []struct {
+		name  string
+		value string
+		loc   *time.Location
+		want  time.Time
+	}{
+		{
+			name:  "plain date is midnight in the location",
+			value: "2024-06-01",
+			loc:   prague,
+			want:  time.Date(2024, time.June, 1, 0, 0, 0, 0, prague),
+		},
+		{
+			name:  "plain date in UTC",
+			value: "2024-06-01",
+			loc:   time.UTC,
+			want:  time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "datetime without offset is wall time",
+			value: "2024-06-01T18:30:00",
+			loc:   prague,
+			want:  time.Date(2024, time.June, 1, 16, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "datetime without seconds",
+			value: "2024-06-01T18:30",
+			loc:   prague,
+			want:  time.Date(2024, time.June, 1, 16, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "offset wins over the location",
+			value: "2024-06-01T18:30:00-05:00",
+			loc:   prague,
+			want:  time.Date(2024, time.June, 1, 23, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "UTC datetime",
+			value: "2024-06-01T18:30:00Z",
+			loc:   prague,
+			want:  time.Date(2024, time.June, 1, 18, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "fractional seconds",
+			value: "2024-06-01T18:30:00.250Z",
+			loc:   prague,
+			want:  time.Date(2024, time.June, 1, 18, 30, 0, 250_000_000, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, validationError := ParseDateTime("Date", test.value, test.loc)
+			if validationError != nil {
+				t.Fatalf("ParseDateTime(%q) error = %+v", test.value, validationError)
+			}
+			if !got.Equal(test.want) {
+				t.Errorf("ParseDateTime(%q) = %v, want %v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseDateTimeErrors(t *testing.T) {
+	for _, value := range []string{"", "01/06/2024", "2024-13-01", "2024-06-01 18:30", "yesterday"} {
+		t.Run(value, func(t *testing.T) {
+			_, validationError := ParseDateTime("Date", value, time.UTC)
+			if validationError == nil {
+				t.Fatalf("ParseDateTime(%q) succeeded, want an error", value)
+			}
+			if validationError.Field != "Date" || validationError.Tag != "datetime" || validationError.Value != value {
+				t.Errorf("ParseDateTime(%q) error = %+v", value, validationError)
+			}
+		})
+	}
+}
+
+func TestParseLogDate(t *testing.T) {
+	auckland := time.FixedZone("NZST", 12*60*60)
+
+	// Late on June 1st in UTC, already June 2nd in Auckland
+	now := time.Date(2024, time.June, 1, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		value   string
+		loc     *time.Location
+		wantErr bool
+	}{
+		{name: "today", value: "2024-06-01", loc: time.UTC},
+		{name: "long ago", value: "2019-01-01", loc: time.UTC},
+		{name: "last allowed day", value: "2024-06-08", loc: time.UTC},
+		{name: "day after the limit", value: "2024-06-09", loc: time.UTC, wantErr: true},
+		{name: "last moment before the limit", value: "2024-06-08T23:59:59Z", loc: time.UTC},
+		{name: "limit itself", value: "2024-06-09T00:00:00Z", loc: time.UTC, wantErr: true},
+		{name: "far future", value: "2099-01-01", loc: time.UTC, wantErr: true},
+		{name: "limit counts from the local day", value: "2024-06-09", loc: auckland},
+		{name: "day after the local limit", value: "2024-06-10", loc: auckland, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, validationError := ParseLogDate("Date", test.value, test.loc, now)
+			if test.wantErr {
+				if validationError == nil {
+					t.Fatalf("ParseLogDate(%q) succeeded, want an error", test.value)
+				}
+				if validationError.Field != "Date" || validationError.Tag != "max" || validationError.Value != test.value {
+					t.Errorf("ParseLogDate(%q) error = %+v", test.value, validationError)
+				}
+				return
+			}
+			if validationError != nil {
+				t.Fatalf("ParseLogDate(%q) error = %+v", test.value, validationError)
+			}
+		})
+	}
+}
+
+func TestParseLogDateInvalid(t *testing.T) {
+	_, validationError := ParseLogDate("Date", "tomorrow", time.UTC, time.Now())
+	if validationError == nil || validationError.Tag != "datetime" {
+		t.Fatalf("ParseLogDate() error = %+v, want an invalid date", validationError)
+	}
+}
@@ -59,6 +59,8 @@ func getErrorMessage(err validator.FieldError) string {
 		return "Invalid email format"
 	case "oneof":
 		return "Invalid value, must be one of the allowed values"
+	case "timezone":
+		return "Invalid timezone, use an IANA name like Europe/Bratislava"
 	default:
 		return "Invalid value"
 	}
@@ -109,13 +109,8 @@ export default function WorkoutsPage() {
     });
   };
 
-  const getStatsForDate = (date: Date) => {
-    const dateStr = format(date, "yyyy-MM-dd");
-    return workoutStats?.find((stat) => {
-      // Extract date part from ISO format (e.g., "2025-07-02T00:00:00Z" -> "2025-07-02")
-      const statDateStr = stat.date.split("T")[0];
-      return statDateStr === dateStr;
-    });
+  const getStatsForWorkout = (workout: Workout) => {
+    return workoutStats?.find((stat) => stat.workoutId === workout.id);
   };
 
   const getWorkoutDays = () => {
@@ -128,7 +123,14 @@ export default function WorkoutsPage() {
   const selectedDateWorkout = selectedDate
     ? getWorkoutForDate(selectedDate)
     : null;
-  const selectedDateStats = selectedDate ? getStatsForDate(selectedDate) : null;
+  const selectedDateStats = selectedDateWorkout
+    ? getStatsForWorkout(selectedDateWorkout)
+    : null;
+  const selectedWorkoutDetails = selectedDateWorkout
+    ? selectedDateDetails?.workouts.find(
+        (details) => details.workoutId === selectedDateWorkout.id,
+      )
+    : null;
 
   const formatSets = (setDetails: { reps: number; weight: number }[]) => {
     return setDetails.map((set) => `${set.reps}x${set.weight}kg`).join(", ");
@@ -283,13 +285,14 @@ export default function WorkoutsPage() {
                         </div>
                       </div>
                     ) : selectedDateDetails ? (
-                      selectedDateDetails.exerciseDetails?.length > 0 ? (
+                      selectedWorkoutDetails &&
+                      selectedWorkoutDetails.exerciseDetails?.length > 0 ? (
                         <div className="p-4 bg-blue-50 rounded-lg border border-blue-200">
                           <div className="text-sm text-blue-700 font-medium mb-3">
                             Exercise Breakdown
                           </div>
                           <div className="space-y-2">
-                            {selectedDateDetails.exerciseDetails.map(
+                            {selectedWorkoutDetails.exerciseDetails.map(
                               (exercise, index) => (
                                 <div
                                   key={index}
@@ -54,7 +54,7 @@ export function EditExerciseForm({ exercise, onSubmit, isLoading = false }: Edit
         totalWeightMultiplier: data.totalWeightMultiplier ? 0.5 : 1.0,
       };
 
-      await ExercisesAPI.updateExercise(exercise.id, updateData);
+      await ExercisesAPI.updateExercise(exercise.id, exercise.version, updateData);
       await onSubmit();
       
       // Reset form and close dialog
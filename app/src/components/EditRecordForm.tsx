@@ -154,7 +154,7 @@ export function EditRecordForm({
         date: data.date || undefined,
       };
 
-      await updateRecord(record.id, updateData);
+      await updateRecord(record.id, record.version, updateData);
       onSuccess();
       setIsOpen(false);
       form.reset(getInitialValues());
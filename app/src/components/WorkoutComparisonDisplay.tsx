@@ -1,5 +1,5 @@
 import { useMemo, useEffect, useState } from "react";
-import { WorkoutStats, DayStats } from "@/lib/types/workout";
+import { WorkoutStats, WorkoutDayStats } from "@/lib/types/workout";
 import { WorkoutsAPI } from "@/lib/api/workouts";
 import { formatDistance, parseISO } from "date-fns";
 
@@ -17,7 +17,7 @@ export function WorkoutComparisonDisplay({
   currentDate,
 }: WorkoutComparisonDisplayProps) {
   const [previousWorkoutDetails, setPreviousWorkoutDetails] =
-    useState<DayStats | null>(null);
+    useState<WorkoutDayStats | null>(null);
   const [loadingPreviousDetails, setLoadingPreviousDetails] = useState(false);
 
   // Find the previous workout with the same name
@@ -28,16 +28,8 @@ export function WorkoutComparisonDisplay({
 
     // Filter workouts with the same name, excluding the current date and future dates
     const sameNameWorkouts = workoutStats.filter((stat) => {
-      // Check if workout names match (handle cases where multiple workouts might be combined with " + ")
-      const statWorkoutNames = stat.workoutName.split(" + ");
-      const currentWorkoutNames = currentWorkoutName.split(" + ");
-
-      // Check if any workout name matches
-      const hasMatchingName = statWorkoutNames.some((name) =>
-        currentWorkoutNames.some(
-          (currentName) => name.trim() === currentName.trim(),
-        ),
-      );
+      const hasMatchingName =
+        stat.workoutName.trim() === currentWorkoutName.trim();
 
       // Only include workouts that are before the current date (not future dates)
       const statDate = new Date(stat.date);
@@ -63,7 +55,11 @@ export function WorkoutComparisonDisplay({
       setLoadingPreviousDetails(true);
       WorkoutsAPI.getWorkoutStatsByDate(previousWorkout.date)
         .then((details) => {
-          setPreviousWorkoutDetails(details);
+          setPreviousWorkoutDetails(
+            details.workouts.find(
+              (workout) => workout.workoutId === previousWorkout.workoutId,
+            ) ?? null,
+          );
         })
         .catch((error) => {
           console.error("Error fetching previous workout details:", error);
@@ -40,11 +40,12 @@ export class ExercisesAPI {
     });
   }
 
-  static async updateExercise(id: string | number, exercise: UpdateExerciseRequest): Promise<Exercise> {
+  static async updateExercise(id: string | number, version: number, exercise: UpdateExerciseRequest): Promise<Exercise> {
     return this.makeRequest<Exercise>(API_CONFIG.ENDPOINTS.EXERCISE_BY_ID(id), {
       method: 'PUT',
       headers: {
         'Content-Type': 'application/json',
+        'If-Match': `"${version}"`,
       },
       body: JSON.stringify(exercise),
     });
@@ -18,14 +18,27 @@ export class RecordsAPI {
       if (response.status === 404) {
         throw new Error('Resource not found');
       }
+      if (response.status === 412) {
+        throw new Error('Record was changed elsewhere, reload and try again');
+      }
       throw new Error(`API request failed: ${response.statusText}`);
     }
 
     return response.json();
   }
 
+  // The API pages records, every page is followed to list them all
   static async getAllRecords(): Promise<RecordsResponse> {
-    return this.makeRequest<RecordsResponse>(API_CONFIG.ENDPOINTS.RECORDS);
+    const records: Record[] = [];
+    let cursor: string | null | undefined;
+    do {
+      const query = cursor ? `?limit=200&cursor=${encodeURIComponent(cursor)}` : '?limit=200';
+      const page = await this.makeRequest<RecordsResponse>(`${API_CONFIG.ENDPOINTS.RECORDS}${query}`);
+      records.push(...page.records);
+      cursor = page.nextCursor;
+    } while (cursor);
+
+    return { records, count: records.length, total: records.length };
   }
 
   static async createRecord(record: CreateRecordRequest): Promise<Record> {
@@ -38,11 +51,12 @@ export class RecordsAPI {
     });
   }
 
-  static async updateRecord(id: number, record: UpdateRecordRequest): Promise<Record> {
+  static async updateRecord(id: number, version: number, record: UpdateRecordRequest): Promise<Record> {
     return this.makeRequest<Record>(API_CONFIG.ENDPOINTS.RECORD_BY_ID(id), {
       method: 'PUT',
       headers: {
         'Content-Type': 'application/json',
+        'If-Match': `"${version}"`,
       },
       body: JSON.stringify(record),
     });
@@ -8,12 +8,12 @@ export function useUpdateRecordMutation() {
   const [isLoading, setIsLoading] = useState(false);
   const [error, setError] = useState<string | null>(null);
 
-  const updateRecord = async (id: number, record: UpdateRecordRequest): Promise<Record> => {
+  const updateRecord = async (id: number, version: number, record: UpdateRecordRequest): Promise<Record> => {
     setIsLoading(true);
     setError(null);
 
     try {
-      const result = await RecordsAPI.updateRecord(id, record);
+      const result = await RecordsAPI.updateRecord(id, version, record);
       return result;
     } catch (err) {
       const errorMessage = err instanceof Error ? err.message : 'Failed to update record';
@@ -4,6 +4,7 @@ export interface Exercise {
   totalWeightMultiplier: number;
   createdAt: string;
   updatedAt: string;
+  version: number;
   images?: string[];
   primaryMuscles?: string[];
   instructions?: string[];
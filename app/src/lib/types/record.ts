@@ -13,6 +13,7 @@ export interface Record {
   id: number;
   createdAt: string;
   updatedAt: string;
+  version: number;
   exerciseId: number;
   exercise: Exercise;
   userId: number;
@@ -23,6 +24,8 @@ export interface Record {
 export interface RecordsResponse {
   records: Record[];
   count: number;
+  total?: number;
+  nextCursor?: string | null;
 }
 
 export interface CreateSetRequest {
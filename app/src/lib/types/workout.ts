@@ -20,6 +20,7 @@ export interface CreateWorkoutRequest {
 
 export interface WorkoutStats {
   date: string;
+  workoutId?: number;
   totalWeight: number;
   workoutName: string;
 }
@@ -40,9 +41,15 @@ export interface ExerciseStats {
   setDetails: SetDetail[];
 }
 
+export interface WorkoutDayStats {
+  workoutId?: number;
+  workoutName: string;
+  totalWeight: number;
+  exerciseDetails: ExerciseStats[];
+}
+
 export interface DayStats {
   date: string;
   totalWeight: number;
-  workoutName: string;
-  exerciseDetails: ExerciseStats[];
+  workouts: WorkoutDayStats[];
 } 
\ No newline at end of file